@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var exportAnki = flag.Bool("anki", false, "export as an Anki deck of flashcards, to import as a text file")
+
+// Anki tags can't have spaces (they separate tags) or quotes in them, so those are
+// dropped or turned into underscores
+var reAnkiTagChars = regexp.MustCompile(`[^\p{L}\p{N}_-]+`)
+
+// ExportAnki writes a card for every paragraph, as the tab separated text files
+// Anki imports: "CCC 484" on the front, the text on the back, and the path to
+// the paragraph as a hierarchical tag, like PART_ONE_...::SECTION_TWO_...
+// The fields are HTML, so line breaks in the text are written as <br>.
+func (c *Catechism) ExportAnki(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "#separator:tab")
+	fmt.Fprintln(bw, "#html:true")
+	fmt.Fprintln(bw, "#tags column:3")
+	for _, num := range sortedNumbers(c.Paragraphs) {
+		p := displayed(c.Paragraphs[num])
+		var back strings.Builder
+		back.WriteString(ankiField(compactText(p.Text)))
+		if len(p.Points) > 0 {
+			back.WriteString("<ul>")
+			for _, point := range p.Points {
+				back.WriteString("<li>" + ankiField(strings.Join(strings.Fields(point), " ")) + "</li>")
+			}
+			back.WriteString("</ul>")
+		}
+		fmt.Fprintf(bw, "CCC %d\t%s\t%s\n", p.Number, back.String(), ankiTag(p.Location()))
+	}
+	return bw.Flush()
+}
+
+// ankiField escapes text for an HTML field, with tabs as spaces and line breaks as
+// <br>. Quotes are escaped too, so Anki never takes a field for a quoted CSV one.
+func ankiField(text string) string {
+	text = html.EscapeString(text)
+	text = strings.ReplaceAll(text, "\t", " ")
+	text = strings.ReplaceAll(text, "\r\n", "<br>")
+	return strings.NewReplacer("\n", "<br>", "\r", "<br>").Replace(text)
+}
+
+// ankiTag turns the titles of a paragraph's path into one hierarchical tag
+func ankiTag(titles []string) string {
+	var parts []string
+	for _, title := range titles {
+		if part := strings.Trim(reAnkiTagChars.ReplaceAllString(title, "_"), "_"); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "::")
+}
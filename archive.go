@@ -0,0 +1,139 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// The largest file an imported archive may contain. Cached pages are a few
+// hundred kilobytes at most, so anything bigger isn't a cached page.
+const maxArchivedFileSize = 16 << 20
+
+// isCacheFile reports whether name is a file ccc puts in the cache directory: the
+// page index, the manifest of hashed names, or a cached page, whose names from
+// urlToFilename always start with the "_" that replaced the path's leading slash,
+// and from hashedFilename too
+func isCacheFile(name string) bool {
+	if name == pageIndexFile || name == cacheManifestFile {
+		return true
+	}
+	return strings.HasPrefix(name, "_") && !strings.ContainsAny(name, `/\`) && !reIllegalFilenameChars.MatchString(name)
+}
+
+// exportCache writes the pages and page index in the cache to a gzipped tar
+// archive, as v1/<file>, so the archive is only ever imported into the same format version
+func exportCache(archive string) (int, error) {
+	entries, err := os.ReadDir(versionedCacheDir)
+	if err != nil {
+		return 0, err
+	}
+	file, err := os.Create(archive)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+	n := 0
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !isCacheFile(entry.Name()) {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(versionedCacheDir, entry.Name()))
+		if err != nil {
+			return n, err
+		}
+		header := &tar.Header{
+			Name:     path.Join(cacheFormatVersion, entry.Name()),
+			Mode:     0644,
+			Size:     int64(len(data)),
+			Typeflag: tar.TypeReg,
+		}
+		if info, err := entry.Info(); err == nil {
+			header.ModTime = info.ModTime()
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return n, err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := tw.Close(); err != nil {
+		return n, err
+	}
+	if err := gz.Close(); err != nil {
+		return n, err
+	}
+	return n, file.Close()
+}
+
+// importCache restores a cache archive written by exportCache. The whole archive
+// is checked before anything is written, so one with unexpected files in it,
+// like ../../.bashrc or a cache from another format version, imports nothing.
+func importCache(archive string) (int, error) {
+	if err := readCacheArchive(archive, nil); err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(versionedCacheDir, 0755); err != nil {
+		return 0, err
+	}
+	n := 0
+	err := readCacheArchive(archive, func(name string, data []byte) error {
+		n++
+		return writeFileAtomic(filepath.Join(versionedCacheDir, name), data)
+	})
+	return n, err
+}
+
+// readCacheArchive checks every file in archive, and passes the ones in the
+// cache to restore, if it isn't nil
+func readCacheArchive(archive string, restore func(name string, data []byte) error) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("%s isn't a gzipped tar archive: %w", archive, err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", archive, err)
+		}
+		if header.Typeflag == tar.TypeDir && strings.TrimSuffix(header.Name, "/") == cacheFormatVersion {
+			continue
+		}
+		dir, name := path.Split(header.Name)
+		if header.Typeflag != tar.TypeReg || dir != cacheFormatVersion+"/" || !isCacheFile(name) {
+			return fmt.Errorf("%s has %q in it, which isn't a %s cache file", archive, header.Name, cacheFormatVersion)
+		}
+		if header.Size > maxArchivedFileSize {
+			return fmt.Errorf("%s has %q in it, which is too big to be a cached page", archive, header.Name)
+		}
+		if restore == nil {
+			continue
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", archive, err)
+		}
+		if err := restore(name, data); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var articleChapter = flag.String("chapter", "", "with article, only look in the chapter whose title contains `text`")
+
+// article prints the article with the number in args, its title and then all
+// of its paragraphs in order. Article numbers start again in every chapter, so
+// if more than one article has the number, --chapter has to pick one of them.
+func article(c *Catechism, args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: ccc article <number> [--chapter text]")
+		exit(2)
+	}
+	num, err := strconv.Atoi(args[0])
+	if err != nil || num < 1 {
+		fmt.Fprintf(os.Stderr, "invalid article number %q\n", args[0])
+		exit(2)
+	}
+	matches := c.articles(num, *articleChapter)
+	switch len(matches) {
+	case 0:
+		fmt.Fprintf(os.Stderr, "article %d not found\n", num)
+		exit(1)
+	case 1:
+		printArticle(c, matches[0])
+	default:
+		fmt.Fprintf(os.Stderr, "there is an article %d in %d chapters, pick one with --chapter:\n", num, len(matches))
+		for _, a := range matches {
+			chapter := a.Parent
+			fmt.Fprintf(os.Stderr, "  %s\n", joinTitles(chapter.Parent.Parent.Title, chapter.Parent.Title, chapter.Title))
+		}
+		exit(2)
+	}
+}
+
+// articles returns the articles numbered num, in order, in the chapters whose
+// titles contain chapter (ignoring case and accents)
+func (c *Catechism) articles(num int, chapter string) []*Article {
+	var matches []*Article
+	for pi := range c.Parts {
+		for si := range c.Parts[pi].Sections {
+			for ci := range c.Parts[pi].Sections[si].Chapters {
+				ch := &c.Parts[pi].Sections[si].Chapters[ci]
+				if !strings.Contains(fold(ch.Title), fold(chapter)) {
+					continue
+				}
+				for ai := range ch.Articles {
+					if ch.Articles[ai].Number == num {
+						matches = append(matches, &ch.Articles[ai])
+					}
+				}
+			}
+		}
+	}
+	return matches
+}
+
+// printArticle prints the title of a, then its paragraphs under their sub-article headings
+func printArticle(c *Catechism, a *Article) {
+	if !*jsonOutput {
+		fmt.Printf("%s\n\n", a.Title)
+	}
+	for _, sub := range a.SubArticles {
+		if sub.Title != "" && !*jsonOutput {
+			if printedParagraphs > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("%s\n\n", sub.Title)
+			// The heading already separates these from the paragraphs before them
+			printedParagraphs = 0
+		}
+		for _, p := range sub.Paragraphs {
+			printParagraph(c.Paragraphs[p.Number])
+		}
+	}
+}
+
+// joinTitles joins the non-empty titles with " > ", like the path command
+func joinTitles(titles ...string) string {
+	var nonEmpty []string
+	for _, title := range titles {
+		if title != "" {
+			nonEmpty = append(nonEmpty, title)
+		}
+	}
+	return strings.Join(nonEmpty, " > ")
+}
+
+// brief prints the "IN BRIEF" summary paragraphs of the articles the paragraphs
+// in args are in, each article's only once
+func brief(c *Catechism, args []string) {
+	ok := true
+	printed := make(map[*Article]bool)
+	for _, p := range paragraphsFromArgs(c.Paragraphs, args) {
+		if p.Parent == nil || p.Parent.Parent.Title == "" {
+			fmt.Fprintf(os.Stderr, "paragraph %d isn't in an article\n", p.Number)
+			ok = false
+			continue
+		}
+		a := p.Parent.Parent
+		if printed[a] {
+			continue
+		}
+		printed[a] = true
+		summary := a.inBrief()
+		if len(summary) == 0 {
+			fmt.Fprintf(os.Stderr, "paragraph %d is in %s, which has no IN BRIEF section\n", p.Number, a.Title)
+			ok = false
+			continue
+		}
+		for _, num := range summary {
+			printParagraph(c.Paragraphs[num])
+		}
+	}
+	if !ok {
+		exit(1)
+	}
+}
+
+// inBrief returns the numbers of the article's "IN BRIEF" paragraphs, in order
+func (a *Article) inBrief() []int {
+	var nums []int
+	for _, sub := range a.SubArticles {
+		for _, p := range sub.Paragraphs {
+			if p.InBrief {
+				nums = append(nums, p.Number)
+			}
+		}
+	}
+	return nums
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Cached responses are stored in this directory, relative to the working directory
+const cacheDir = "cache"
+
+// The version of the on-disk cache format. Bump this whenever the way pages or the
+// page index are stored changes, so that a new build never reads stale files.
+const cacheFormatVersion = "v1"
+
+// Everything this build caches lives in here, other versions are left alone until `ccc cache clear`
+var versionedCacheDir = filepath.Join(cacheDir, cacheFormatVersion)
+
+// A Cache stores the dumped HTTP responses for pages, keyed by their URL
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Put(key string, data []byte) error
+}
+
+// A StreamingCache can also open a cached response for reading, so that getOnce
+// doesn't have to hold the whole response in memory
+type StreamingCache interface {
+	Open(key string) (io.ReadCloser, bool)
+}
+
+// The cache used by getOnce
+var pageCache Cache = fileCache{dir: versionedCacheDir}
+
+var noCache = flag.Bool("no-cache", false, "don't read or write anything in cache/, fetch every page and keep it in memory")
+var hashedCache = flag.Bool("hashed-cache", false, "name cached pages by the sha256 of their URL instead of its path, listing the URLs in cache/v1/urls.json")
+
+// useHashedCache switches to cache files named by hashedFilename, for --hashed-cache
+func useHashedCache() {
+	pageCache = fileCache{dir: versionedCacheDir, hashed: true}
+}
+
+// useMemoryCache switches to a cache that only lasts as long as the process,
+// for --no-cache. The page index isn't read or saved either.
+func useMemoryCache() {
+	pageCache = &memoryCache{pages: make(map[string][]byte)}
+}
+
+// memoryCache is a Cache that never touches the filesystem
+type memoryCache struct {
+	mu    sync.Mutex
+	pages map[string][]byte
+}
+
+func (c *memoryCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.pages[key]
+	return data, ok
+}
+
+func (c *memoryCache) Put(key string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pages[key] = data
+	return nil
+}
+
+// fileCache is the default Cache, which keeps each response in its own file in
+// dir, named by urlToFilename, or if hashed by hashedFilename
+type fileCache struct {
+	dir    string
+	hashed bool
+}
+
+func (c fileCache) Get(key string) ([]byte, bool) {
+	filename, err := c.filename(key)
+	if err != nil {
+		return nil, false
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+func (c fileCache) Open(key string) (io.ReadCloser, bool) {
+	filename, err := c.filename(key)
+	if err != nil {
+		return nil, false
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, false
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{bufio.NewReader(file), file}, true
+}
+
+func (c fileCache) Put(key string, data []byte) error {
+	filename, err := c.filename(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filename, data); err != nil {
+		return err
+	}
+	if c.hashed {
+		return addToManifest(c.dir, hashedFilename(key), normalizeCacheURL(key))
+	}
+	return nil
+}
+
+func (c fileCache) filename(key string) (string, error) {
+	if c.hashed {
+		return filepath.Join(c.dir, hashedFilename(key)), nil
+	}
+	name, err := urlToFilename(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(c.dir, name), nil
+}
+
+// urlToFilename returns the name the page at urlStr is cached under. The name is
+// always a single path element, never "." or "..", so it can't point outside the
+// cache directory whatever the URL is. Pages from the Vatican are named by their
+// path alone. Other hosts, like other editions', come first in the name, as if
+// they were the path's first directory, so the same path on two hosts doesn't
+// end up with the same name.
+func urlToFilename(urlStr string) (string, error) {
+	// Parse the URL
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return "", fmt.Errorf("error parsing url %s: %w", urlStr, err)
+	}
+
+	// Extract the path, after the host unless it's the Vatican's
+	path := u.Path
+	if host := strings.ToLower(u.Host); host != "" && host != vaticanHost {
+		path = "/" + host + path
+	}
+
+	// Replace slashes (either way round) with underscores and remove trailing slash
+	path = strings.TrimRight(strings.NewReplacer("/", "_", "\\", "_").Replace(path), "_")
+
+	// Remove any illegal characters using a regular expression
+	path = reIllegalFilenameChars.ReplaceAllString(path, "")
+
+	// Make the path safe for the filesystem
+	path = filepath.Clean(path)
+	if path == "." || path == ".." {
+		// An empty path, or one of just dots, would name the directory or its parent
+		path = "_" + path
+	}
+	return path, nil
+}
+
+// hashedFilename returns the name the page at urlStr is cached under with
+// --hashed-cache: "_" and the sha256 of the normalized URL in hex. Unlike
+// urlToFilename's, no two URLs share a name, even if they only differ in their
+// query string or in characters a filename can't have.
+func hashedFilename(urlStr string) string {
+	sum := sha256.Sum256([]byte(normalizeCacheURL(urlStr)))
+	return "_" + hex.EncodeToString(sum[:])
+}
+
+// normalizeCacheURL returns urlStr with the scheme and host in lower case and
+// without its fragment, which only says where on the page to scroll to
+func normalizeCacheURL(urlStr string) string {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return urlStr
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	return u.String()
+}
+
+// The manifest maps each name from hashedFilename to the URL it's the hash of,
+// which can't be told from the name
+const cacheManifestFile = "urls.json"
+
+// Pages are prefetched in parallel, so adding them to the manifest has to take turns
+var manifestMu sync.Mutex
+
+// loadManifest reads the manifest in dir, or returns an empty one if there isn't one
+func loadManifest(dir string) (map[string]string, error) {
+	manifest := make(map[string]string)
+	data, err := ioutil.ReadFile(filepath.Join(dir, cacheManifestFile))
+	if os.IsNotExist(err) {
+		return manifest, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("%s is corrupt: %w", cacheManifestFile, err)
+	}
+	return manifest, nil
+}
+
+// addToManifest records in dir's manifest that the page at urlStr is cached as name
+func addToManifest(dir, name, urlStr string) error {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	manifest, err := loadManifest(dir)
+	if err != nil {
+		return err
+	}
+	if manifest[name] == urlStr {
+		return nil
+	}
+	manifest[name] = urlStr
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, cacheManifestFile), data)
+}
+
+// Characters that aren't allowed in filenames on some systems, and control characters
+var reIllegalFilenameChars = regexp.MustCompile(`[<>:"|?*\x00-\x1f]`)
+
+// writeFileAtomic writes data to a temporary file next to filename, then renames it into place,
+// so an interrupted write never leaves a partial file behind
+func writeFileAtomic(filename string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), ".tmp-"+filepath.Base(filename)+"-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// clearCache removes every cached response, including those from other cache
+// format versions, but keeps the cache directory itself
+func clearCache() error {
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return os.MkdirAll(cacheDir, 0755)
+		}
+		return err
+	}
+	for _, entry := range entries {
+		// .keep is checked in so that the directory exists in a fresh clone
+		if entry.Name() == ".keep" {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(cacheDir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cacheCommand runs `ccc cache <subcommand>`
+func cacheCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: ccc cache info|clear|verify [--prune]|export file.tar.gz|import file.tar.gz")
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "info":
+		cacheInfo()
+	case "verify":
+		verifyCache()
+	case "export", "import":
+		if len(args) != 2 {
+			fmt.Fprintf(os.Stderr, "usage: ccc cache %s file.tar.gz\n", args[0])
+			os.Exit(2)
+		}
+		if args[0] == "export" {
+			n, err := exportCache(args[1])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error exporting cache: %s\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("exported %d files from %s/ to %s\n", n, versionedCacheDir, args[1])
+		} else {
+			n, err := importCache(args[1])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error importing cache: %s\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("imported %d files from %s to %s/\n", n, args[1], versionedCacheDir)
+		}
+	case "clear":
+		if err := clearCache(); err != nil {
+			fmt.Fprintf(os.Stderr, "error clearing cache: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("cleared %s/\n", cacheDir)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown cache command %q\n", args[0])
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// The layout cache times are printed in, in the local timezone
+const cacheTimeLayout = "Mon 2 Jan 2006 15:04 MST"
+
+// cacheInfo prints how many pages are cached, how much space they take, and
+// when the oldest and newest of them were fetched, so it's clear how stale the cache is
+func cacheInfo() {
+	entries, err := os.ReadDir(versionedCacheDir)
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "error reading %s/: %s\n", versionedCacheDir, err)
+		os.Exit(1)
+	}
+	var pages, hashed int
+	var size int64
+	var oldest, newest time.Time
+	for _, entry := range entries {
+		if entry.IsDir() || !isCacheFile(entry.Name()) || entry.Name() == pageIndexFile || entry.Name() == cacheManifestFile {
+			continue
+		}
+		if reHashedFilename.MatchString(entry.Name()) {
+			hashed++
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		pages++
+		size += info.Size()
+		if mtime := info.ModTime(); oldest.IsZero() || mtime.Before(oldest) {
+			oldest = mtime
+		}
+		if mtime := info.ModTime(); mtime.After(newest) {
+			newest = mtime
+		}
+	}
+	fmt.Printf("%s/: %d pages, %s\n", versionedCacheDir, pages, formatSize(size))
+	if pages == 0 {
+		return
+	}
+	fmt.Printf("oldest page: %s\n", formatCacheTime(oldest))
+	fmt.Printf("newest page: %s\n", formatCacheTime(newest))
+	if info, err := os.Stat(filepath.Join(versionedCacheDir, pageIndexFile)); err == nil {
+		fmt.Printf("page index:  %s\n", formatCacheTime(info.ModTime()))
+	} else {
+		fmt.Println("page index:  none, run `ccc crawl` to make one")
+	}
+	if hashed > 0 {
+		manifest, err := loadManifest(versionedCacheDir)
+		if err != nil {
+			fmt.Printf("hashed names: %d pages, but %s\n", hashed, err)
+		} else {
+			fmt.Printf("hashed names: %d pages, %d URLs in %s\n", hashed, len(manifest), cacheManifestFile)
+		}
+	}
+}
+
+// Matches the names of pages cached with --hashed-cache, see hashedFilename
+var reHashedFilename = regexp.MustCompile(`^_[0-9a-f]{64}$`)
+
+// formatCacheTime formats t in the local timezone, with how long ago it was
+func formatCacheTime(t time.Time) string {
+	return fmt.Sprintf("%s (%s)", t.In(time.Local).Format(cacheTimeLayout), ago(time.Since(t)))
+}
+
+// ago describes a duration in the largest whole unit, like "3 days ago"
+func ago(d time.Duration) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s ago", unit)
+		}
+		return fmt.Sprintf("%d %ss ago", n, unit)
+	}
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return plural(int(d/time.Minute), "minute")
+	case d < 24*time.Hour:
+		return plural(int(d/time.Hour), "hour")
+	default:
+		return plural(int(d/(24*time.Hour)), "day")
+	}
+}
+
+// formatSize formats a number of bytes like "1.2 MB"
+func formatSize(n int64) string {
+	switch {
+	case n < 1000:
+		return fmt.Sprintf("%d bytes", n)
+	case n < 1000*1000:
+		return fmt.Sprintf("%.1f kB", float64(n)/1000)
+	default:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1000*1000))
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+var prune = flag.Bool("prune", false, "with cache verify, delete the files that are bad")
+
+// verifyCache checks every file in the cache, and prints the ones that are bad:
+// pages that aren't a whole HTTP response, server errors, and pages with neither
+// paragraphs nor links in them, which is what an error page looks like (the
+// glossary has no numbered paragraphs, but its pages link to the index). With --prune the bad files are deleted, so the next crawl fetches them again.
+func verifyCache() {
+	entries, err := os.ReadDir(versionedCacheDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading %s: %s\n", versionedCacheDir, err)
+		os.Exit(1)
+	}
+	checked, bad := 0, 0
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !isCacheFile(entry.Name()) {
+			continue
+		}
+		name := filepath.Join(versionedCacheDir, entry.Name())
+		checked++
+		var problem error
+		if entry.Name() == pageIndexFile || entry.Name() == cacheManifestFile {
+			problem = checkIndexFile(name)
+		} else {
+			problem = checkCachedPage(name)
+		}
+		if problem == nil {
+			continue
+		}
+		bad++
+		fmt.Printf("%s: %s\n", name, problem)
+		if *prune {
+			if err := os.Remove(name); err != nil {
+				fmt.Fprintf(os.Stderr, "error removing %s: %s\n", name, err)
+				os.Exit(1)
+			}
+		}
+	}
+	fmt.Printf("checked %d files, %d bad", checked, bad)
+	if bad > 0 && *prune {
+		fmt.Print(", deleted them")
+	}
+	fmt.Println()
+	if bad > 0 && !*prune {
+		os.Exit(1)
+	}
+}
+
+// checkCachedPage returns what's wrong with the cached page in name, if anything
+func checkCachedPage(name string) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	doc, status, _, err := readPage(file)
+	if err != nil {
+		return err
+	}
+	if status >= http.StatusInternalServerError {
+		return fmt.Errorf("the server returned %d %s", status, http.StatusText(status))
+	}
+	var state parseState
+	if len(parsePage(doc, "", &state)) == 0 && doc.Find("a[href]").Length() == 0 {
+		return fmt.Errorf("no paragraphs or links in it")
+	}
+	return nil
+}
+
+// checkIndexFile returns an error if the page index or the manifest of hashed
+// names in name isn't valid JSON. Both map strings to URLs.
+func checkIndexFile(name string) error {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	var index map[string]string
+	if err := json.Unmarshal(data, &index); err != nil {
+		return fmt.Errorf("not a valid %s: %w", filepath.Base(name), err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// A full crawl saves its progress here after every page, so that an interrupted
+// crawl can pick up where it left off. It's removed once a crawl finishes.
+const checkpointFile = "checkpoint.json"
+
+// A crawlCheckpoint is everything Crawl needs to carry on from Next
+type crawlCheckpoint struct {
+	FirstPage  string                `json:"first_page"` // which edition was being crawled
+	Next       string                `json:"next"`       // the page to crawl next
+	Pages      int                   `json:"pages"`
+	Visited    []string              `json:"visited"`
+	Failed     []string              `json:"failed,omitempty"` // the pages skipped since they couldn't be fetched
+	Paragraphs []checkpointParagraph `json:"paragraphs"`       // in the order they were found
+	Duplicates []int                 `json:"duplicates,omitempty"`
+	InBrief    bool                  `json:"in_brief,omitempty"`
+	Location   checkpointLocation    `json:"location"`
+}
+
+// checkpointLocation is a location with exported fields, so it can be saved
+type checkpointLocation struct {
+	Part       string `json:"part,omitempty"`
+	Section    string `json:"section,omitempty"`
+	Chapter    string `json:"chapter,omitempty"`
+	Article    string `json:"article,omitempty"`
+	SubArticle string `json:"sub_article,omitempty"`
+}
+
+type checkpointParagraph struct {
+	Paragraph
+	Location checkpointLocation `json:"location"`
+}
+
+func newCheckpoint(firstPage, next string, pages int, visited map[string]bool, failed []string, inOrder []Paragraph, duplicates []int, state parseState) crawlCheckpoint {
+	cp := crawlCheckpoint{
+		FirstPage:  firstPage,
+		Next:       next,
+		Pages:      pages,
+		Failed:     failed,
+		Duplicates: duplicates,
+		InBrief:    state.inBrief,
+		Location:   newCheckpointLocation(state.loc),
+	}
+	for urlStr := range visited {
+		cp.Visited = append(cp.Visited, urlStr)
+	}
+	sort.Strings(cp.Visited)
+	for _, p := range inOrder {
+		cp.Paragraphs = append(cp.Paragraphs, checkpointParagraph{p, newCheckpointLocation(p.loc)})
+	}
+	return cp
+}
+
+func newCheckpointLocation(loc location) checkpointLocation {
+	return checkpointLocation{loc.part, loc.section, loc.chapter, loc.article, loc.subArticle}
+}
+
+func (loc checkpointLocation) location() location {
+	return location{loc.Part, loc.Section, loc.Chapter, loc.Article, loc.SubArticle}
+}
+
+// saveCheckpoint records the progress of a crawl. Failing to save it only
+// means a resumed crawl starts over, so errors are just logged under --verbose.
+func saveCheckpoint(cp crawlCheckpoint) {
+	if *noCache {
+		return
+	}
+	data, err := json.Marshal(cp)
+	if err == nil {
+		err = os.MkdirAll(versionedCacheDir, 0755)
+	}
+	if err == nil {
+		err = writeFileAtomic(filepath.Join(versionedCacheDir, checkpointFile), data)
+	}
+	if err != nil {
+		verbosef("warning: couldn't save the crawl checkpoint: %s", err)
+	}
+}
+
+// loadCheckpoint returns the saved progress of an unfinished crawl starting at firstPage, if there is one
+func loadCheckpoint(firstPage string) (crawlCheckpoint, bool) {
+	var cp crawlCheckpoint
+	if *noCache {
+		return cp, false
+	}
+	data, err := ioutil.ReadFile(filepath.Join(versionedCacheDir, checkpointFile))
+	if err != nil {
+		return cp, false
+	}
+	if err := json.Unmarshal(data, &cp); err != nil || cp.FirstPage != firstPage || cp.Next == "" {
+		return cp, false
+	}
+	return cp, true
+}
+
+// removeCheckpoint deletes the checkpoint once a crawl is finished
+func removeCheckpoint() {
+	if *noCache {
+		return
+	}
+	if err := os.Remove(filepath.Join(versionedCacheDir, checkpointFile)); err != nil && !os.IsNotExist(err) {
+		verbosef("warning: couldn't remove the crawl checkpoint: %s", err)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+var bibtex = flag.Bool("bibtex", false, "cite as a BibTeX entry")
+
+// The publication details of the edition on the Vatican's website, for BibTeX entries
+const (
+	bibAuthor    = "{Catholic Church}"
+	bibTitle     = "Catechism of the Catholic Church"
+	bibEdition   = "Second"
+	bibPublisher = "Libreria Editrice Vaticana"
+	bibAddress   = "Vatican City"
+	bibYear      = "1997"
+)
+
+// cite prints a citation for each paragraph in args, or, with --bibtex and
+// no paragraphs, a BibTeX entry for the catechism as a whole
+func cite(paragraphs map[int]Paragraph, args []string) {
+	if *bibtex && len(args) == 0 {
+		fmt.Print(bibtexBook())
+		return
+	}
+	for _, p := range paragraphsFromArgs(paragraphs, args) {
+		if *bibtex {
+			fmt.Print(bibtexParagraph(p))
+		} else {
+			fmt.Println(p.Citation())
+		}
+	}
+}
+
+// bibtexBook returns the @book entry for the whole catechism
+func bibtexBook() string {
+	return fmt.Sprintf(`@book{ccc,
+  author    = {%s},
+  title     = {%s},
+  edition   = {%s},
+  publisher = {%s},
+  address   = {%s},
+  year      = {%s},
+  url       = {%s}
+}
+`, bibAuthor, bibTitle, bibEdition, bibPublisher, bibAddress, bibYear, vaticanIndexPage)
+}
+
+// bibtexParagraph returns a @misc entry for one paragraph, with its number in the note
+func bibtexParagraph(p Paragraph) string {
+	urlStr := p.SourceURL
+	if urlStr == "" {
+		urlStr = vaticanIndexPage
+	}
+	return fmt.Sprintf(`@misc{ccc%d,
+  author    = {%s},
+  title     = {%s},
+  edition   = {%s},
+  publisher = {%s},
+  address   = {%s},
+  year      = {%s},
+  note      = {%s},
+  url       = {%s}
+}
+`, p.Number, bibAuthor, bibTitle, bibEdition, bibPublisher, bibAddress, bibYear, p.Citation(), urlStr)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// The language editions ccc knows how to crawl, by language code, and their first pages.
+// The Vatican's other editions aren't laid out like the English one, so they have to be
+// added with --edition, once checked that the parser handles them.
+var editions = map[string]string{
+	"en": vaticanFirstPage,
+}
+
+// The languages to compare, from repeated --lang flags
+var languages stringsFlag
+
+func init() {
+	flag.Var(&languages, "lang", "a language `code` to compare, like en (can be repeated)")
+	flag.Var(editionFlag{}, "edition", "crawl the `code=url` edition starting at url (can be repeated)")
+}
+
+// stringsFlag collects a flag that can be repeated
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ", ")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// editionFlag adds a language edition from a `code=url` flag
+type editionFlag struct{}
+
+func (editionFlag) String() string {
+	var codes []string
+	for code := range editions {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return strings.Join(codes, ", ")
+}
+
+func (editionFlag) Set(value string) error {
+	code, urlStr, found := strings.Cut(value, "=")
+	if !found || code == "" || urlStr == "" {
+		return fmt.Errorf("malformed edition %q, expected 'code=url'", value)
+	}
+	editions[code] = urlStr
+	return nil
+}
+
+// newEditionCrawler returns a Crawler for the lang edition starting at
+// firstPage. It's laid out like the English one, but its links are resolved
+// against its own pages rather than the English archive's, and since there's
+// only one checkpoint, which is the English crawl's, it isn't checkpointed.
+func newEditionCrawler(lang, firstPage string) (*Crawler, error) {
+	base, err := url.Parse(firstPage)
+	if err != nil {
+		return nil, err
+	}
+	if scheme := strings.ToLower(base.Scheme); (scheme != "http" && scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("%q isn't a web page", firstPage)
+	}
+	resolve := func(href string) (string, error) {
+		rel, err := url.Parse(href)
+		if err != nil {
+			return "", err
+		}
+		resolved := base.ResolveReference(rel)
+		if scheme := strings.ToLower(resolved.Scheme); scheme != "http" && scheme != "https" {
+			return "", fmt.Errorf("%q isn't a page in the edition", href)
+		}
+		return resolved.String(), nil
+	}
+	index, _ := resolve("_INDEX.HTM")
+	crawler := NewVaticanCrawler(firstPage)
+	crawler.NextPage = func(doc *goquery.Document, visited map[string]bool) string {
+		return nextLink(doc, visited, resolve, index)
+	}
+	// linkedPages resolves against the English archive too, so don't prefetch
+	crawler.LinkedPages = nil
+	crawler.Lang = lang
+	crawler.Checkpoint = false
+	return crawler, nil
+}
+
+// compare prints paragraph num from each --lang edition, one after another with
+// the language as a label, so the translations can be read side by side
+func compare(ctx context.Context, args []string) {
+	if len(args) != 1 || len(languages) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: ccc compare <number> --lang en --lang <code> ...")
+		os.Exit(2)
+	}
+	nums, err := parseParagraphSpec(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(2)
+	}
+	for _, lang := range languages {
+		if _, ok := editions[lang]; !ok {
+			fmt.Fprintf(os.Stderr, "no %q edition, add one with --edition %s=<first page url>\n", lang, lang)
+			os.Exit(2)
+		}
+	}
+	for i, lang := range languages {
+		var paragraphs map[int]Paragraph
+		if lang == "en" {
+			paragraphs = getCatechism(ctx)
+		} else {
+			crawler, err := newEditionCrawler(lang, editions[lang])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "invalid --edition %s: %s\n", lang, err)
+				os.Exit(2)
+			}
+			c, _, _ := crawler.Crawl(ctx, nil)
+			paragraphs = c.Paragraphs
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		for _, num := range nums {
+			fmt.Printf("[%s]\n", lang)
+			if p, found := paragraphs[num]; found {
+				fmt.Println(displayed(p))
+			} else {
+				fmt.Printf("paragraph %d is not in this edition\n", num)
+			}
+		}
+	}
+}
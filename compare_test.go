@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestEditionNextLink(t *testing.T) {
+	crawler, err := newEditionCrawler("xx", "https://example.org/ccc/first.htm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<p><a href="second.htm#top">Next</a></p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Resolved against the edition's own pages, not the English archive
+	if next, want := crawler.NextPage(doc, nil), "https://example.org/ccc/second.htm"; next != want {
+		t.Errorf("got %q, want %q", next, want)
+	}
+	// The edition's table of contents ends its crawl like the English one's
+	doc = readFixture(t, "last_page.html")
+	if next := crawler.NextPage(doc, nil); next != "" {
+		t.Errorf("got %q after the last page, want none", next)
+	}
+	if crawler.Checkpoint {
+		t.Error("an edition's crawl shouldn't share the English crawl's checkpoint")
+	}
+}
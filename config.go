@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// configPath returns where the config file is, $XDG_CONFIG_HOME/ccc/config.toml
+// (which is ~/.config/ccc/config.toml when XDG_CONFIG_HOME isn't set)
+func configPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "ccc", "config.toml")
+}
+
+// envName returns the environment variable for a flag, like CCC_USER_AGENT for --user-agent
+func envName(flagName string) string {
+	return "CCC_" + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// applyDefaults sets each flag that wasn't given on the command line from its
+// environment variable, or failing that from the config file, so the precedence
+// is flags > environment > config file > built in defaults
+func applyDefaults() error {
+	setOnCommandLine := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		setOnCommandLine[f.Name] = true
+	})
+
+	config := make(map[string][]string)
+	if path := configPath(); path != "" {
+		file, err := os.Open(path)
+		if err == nil {
+			config, err = parseConfig(file)
+			file.Close()
+			if err != nil {
+				return fmt.Errorf("%s: %s", path, err)
+			}
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	for name := range config {
+		if flag.Lookup(name) == nil {
+			return fmt.Errorf("%s: unknown setting %q", configPath(), name)
+		}
+	}
+
+	var err error
+	flag.VisitAll(func(f *flag.Flag) {
+		if err != nil || setOnCommandLine[f.Name] {
+			return
+		}
+		values, ok := config[f.Name]
+		if env, found := os.LookupEnv(envName(f.Name)); found {
+			values, ok = []string{env}, true
+		}
+		if !ok {
+			return
+		}
+		for _, value := range values {
+			if setErr := flag.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid value %q for %s: %s", value, f.Name, setErr)
+				return
+			}
+		}
+	})
+	return err
+}
+
+// parseConfig reads the simple subset of TOML the config file uses: one
+// `name = value` setting per line, where value is a string, number, boolean
+// or an array of strings (for flags that can be repeated, like header), and
+// # starts a comment. Names are the same as the command line flags.
+func parseConfig(r io.Reader) (map[string][]string, error) {
+	config := make(map[string][]string)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		name, value, found := strings.Cut(text, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return nil, fmt.Errorf("line %d: expected name = value", line)
+		}
+		values, err := parseConfigValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		config[name] = values
+	}
+	return config, scanner.Err()
+}
+
+// parseConfigValue parses the right hand side of a setting into one or more flag values
+func parseConfigValue(value string) ([]string, error) {
+	var values []string
+	var rest string
+	var err error
+	switch {
+	case strings.HasPrefix(value, "["):
+		rest = strings.TrimSpace(value[1:])
+		for !strings.HasPrefix(rest, "]") {
+			var str string
+			if str, rest, err = cutString(rest); err != nil {
+				return nil, fmt.Errorf("arrays can only hold strings: %s", value)
+			}
+			values = append(values, str)
+			rest = strings.TrimSpace(rest)
+			if strings.HasPrefix(rest, ",") {
+				rest = strings.TrimSpace(rest[1:])
+			} else if !strings.HasPrefix(rest, "]") {
+				return nil, fmt.Errorf("unterminated array %s", value)
+			}
+		}
+		rest = rest[1:]
+	case strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'"):
+		var str string
+		if str, rest, err = cutString(value); err != nil {
+			return nil, err
+		}
+		values = append(values, str)
+	default:
+		// A bare number or boolean
+		value, rest, _ = strings.Cut(value, "#")
+		values = append(values, strings.TrimSpace(value))
+		rest = "#" + rest
+	}
+	if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+		return nil, fmt.Errorf("unexpected %s after value", rest)
+	}
+	return values, nil
+}
+
+// cutString cuts the string at the start of s off of it, returning the string and what follows.
+// Basic strings ("a\tb") have escapes like Go's, literal strings ('a\b') are taken as they are.
+func cutString(s string) (string, string, error) {
+	if strings.HasPrefix(s, "'") {
+		end := strings.Index(s[1:], "'")
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string %s", s)
+		}
+		return s[1 : end+1], s[end+2:], nil
+	}
+	quoted, err := strconv.QuotedPrefix(s)
+	if err != nil || !strings.HasPrefix(quoted, `"`) {
+		return "", "", fmt.Errorf("bad string %s", s)
+	}
+	str, err := strconv.Unquote(quoted)
+	if err != nil {
+		return "", "", fmt.Errorf("bad string %s", s)
+	}
+	return str, s[len(quoted):], nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+var (
+	limitPages = flag.Int("limit-pages", 0, "with crawl, stop after the first `N` pages")
+	startPage  = flag.String("start-page", "", "with crawl, start at the archive's page `url`, like __P1A.HTM, instead of its first page")
+	planJSON   = flag.Bool("plan-json", false, "with crawl, print the pages in the order they were crawled as JSON, with each one's \"Next\" page and paragraph count")
+)
+
+// A crawledPage is one step of a crawl, for --plan-json
+type crawledPage struct {
+	URL string `json:"url"`
+	// The page crawled after this one, or "" if the crawl ended here
+	Next       string `json:"next,omitempty"`
+	Paragraphs int    `json:"paragraphs"`
+	Bytes      int64  `json:"bytes"`
+	// The page a "Next" link that wasn't followed went back to, since it was already crawled
+	Loop   string `json:"loop,omitempty"`
+	Failed bool   `json:"failed,omitempty"`
+}
+
+// crawlCommand crawls the catechism, or with --limit-pages just its first pages,
+// and reports what it found. It's for testing the parser without a full crawl,
+// so a partial crawl doesn't replace the page index. Neither does a crawl that had
+// to skip pages it couldn't fetch, and crawlCommand exits non-zero after one.
+// With --plan-json, it prints every page it crawled instead of a summary. A
+// crawl from --start-page doesn't replace the page index either, since it
+// doesn't have the pages before that one.
+func crawlCommand(ctx context.Context) {
+	if *limitPages < 0 {
+		fmt.Fprintln(os.Stderr, "--limit-pages can't be negative")
+		os.Exit(2)
+	}
+	crawler := NewVaticanCrawler(vaticanFirstPage)
+	crawler.MaxPages = *limitPages
+	if *startPage != "" {
+		start, err := vaticanURL(*startPage)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid --start-page: %s\n", err)
+			os.Exit(2)
+		}
+		crawler.Start = start
+		crawler.Partial = start != vaticanFirstPage
+	}
+	c, pages, partial := crawler.Crawl(ctx, nil)
+	if !partial && len(c.failedPages) == 0 {
+		if err := savePageIndex(buildPageIndex(c.Paragraphs)); err != nil {
+			fmt.Fprintf(os.Stderr, "error saving page index: %s\n", err)
+		}
+	}
+	if *planJSON {
+		printCrawlPlan(c.plan)
+	} else {
+		printCrawlSummary(crawler, c, pages, partial)
+	}
+	if len(c.failedPages) > 0 {
+		os.Exit(1)
+	}
+}
+
+// printCrawlSummary says how many pages and paragraphs cr's crawl found, and
+// whether it's only part of the catechism
+func printCrawlSummary(cr *Crawler, c *Catechism, pages int, partial bool) {
+	nums := sortedNumbers(c.Paragraphs)
+	fmt.Printf("crawled %d pages and found %d paragraphs", pages, len(nums))
+	if len(nums) > 0 {
+		fmt.Printf(" (%d to %d)", nums[0], nums[len(nums)-1])
+	}
+	fmt.Println()
+	if cr.Partial {
+		fmt.Printf("this is a partial crawl: it started at %s, after the start of the catechism\n", cr.Start)
+	} else if partial {
+		fmt.Printf("this is a partial crawl: it stopped after %d pages, before the end of the catechism\n", pages)
+	}
+}
+
+// printCrawlPlan prints the pages of a crawl, in order, as a JSON array. A
+// crawl that was resumed from a checkpoint only has the pages since then.
+func printCrawlPlan(plan []crawledPage) {
+	if plan == nil {
+		plan = []crawledPage{}
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(plan); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing the crawl plan: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// A Crawler crawls an archive whose pages are read one after another, like the
+// pages of a book, starting at Start and following each page's link to the next
+type Crawler struct {
+	Start string
+	// NextPage returns the URL of the page after doc, or "" if doc is the last
+	// one. It mustn't return a page in visited, which have been crawled already.
+	NextPage func(doc *goquery.Document, visited map[string]bool) string
+	// LinkedPages, if it isn't nil, returns every page doc links to, in order.
+	// They're prefetched with --prefetch, and when a page can't be fetched the
+	// crawl carries on from the first one linked to after it.
+	LinkedPages func(doc *goquery.Document) []string
+	// Parser finds the paragraphs on each page
+	Parser   Parser
+	MaxPages int // stop after this many pages, unless it's 0
+	// Lang is the code of the edition being crawled, whose last paragraph
+	// --strict checks the numbering against, see lastParagraph
+	Lang string
+	// Checkpoint saves the crawl's progress in the checkpoint, which there's
+	// only one of, so that an interrupted crawl can carry on from there
+	Checkpoint bool
+	// Partial says that Start isn't the first page, like a crawl from
+	// --start-page, so however far the crawl goes it's only part of the
+	// catechism. It isn't checkpointed, its numbering isn't checked for gaps,
+	// and the paragraphs before the first heading it finds have no part.
+	Partial bool
+}
+
+// NewVaticanCrawler returns a Crawler for the Vatican's archive of the
+// catechism, starting at start, which follows the pages' "Next" links
+func NewVaticanCrawler(start string) *Crawler {
+	return &Crawler{
+		Start:       start,
+		NextPage:    getNextLink,
+		LinkedPages: linkedPages,
+		Parser:      NewVaticanParser(),
+		Lang:        "en",
+		Checkpoint:  true,
+	}
+}
+
+// Crawl crawls from cr.Start, following each page's link to the next one, and
+// reads the pages from the cache where possible. It stops after cr.MaxPages
+// pages unless that's 0, and returns how many pages were crawled and whether it
+// stopped early with pages left to go, in which case the catechism is only
+// partial, which it always is if cr.Partial is set. A page that can't be
+// fetched is skipped, and the crawl carries on from the next page linked to
+// after it, if it knows of one. If emit isn't nil, it's called with each new
+// paragraph as it's found.
+func (cr *Crawler) Crawl(ctx context.Context, emit func(Paragraph)) (c *Catechism, pages int, partial bool) {
+	c, pages, partial, err := cr.TryCrawl(ctx, emit)
+	if err != nil {
+		exitWithCrawlError(err)
+	}
+	return c, pages, partial
+}
+
+// TryCrawl is Crawl, but returns an error instead of exiting when the crawl
+// can't carry on: when ctx is cancelled, a cached page can't be read, or with
+// --strict something is wrong with the result. It's for callers that have to
+// keep running, like the server.
+func (cr *Crawler) TryCrawl(ctx context.Context, emit func(Paragraph)) (c *Catechism, pages int, partial bool, err error) {
+	var urlStr string = cr.Start
+	pp := newPageParser(cr.Parser, emit)
+	// Pages already crawled, so that a "Next" link back to one of them can't cause a loop
+	var visited = make(map[string]bool)
+	// Pages that couldn't be fetched, and every page linked to so far, in order,
+	// to find a way past them
+	var failed, links []string
+	var plan []crawledPage
+	// A full crawl that was interrupted carries on from its checkpoint. Only
+	// the Vatican's parser can be resumed, since its state is saved with it.
+	vp, isVatican := cr.Parser.(*VaticanParser)
+	resumable := cr.Checkpoint && !cr.Partial && cr.MaxPages == 0 && isVatican
+	if cp, ok := loadCheckpoint(cr.Start); ok && resumable {
+		fmt.Fprintf(os.Stderr, "resuming the crawl from %s\n", cp.Next)
+		urlStr = cp.Next
+		pages = cp.Pages
+		for _, v := range cp.Visited {
+			visited[v] = true
+		}
+		// The pages skipped before it was interrupted are still missing, and
+		// still have to be reported
+		failed = cp.Failed
+		for _, saved := range cp.Paragraphs {
+			p := saved.Paragraph
+			p.loc = saved.Location.location()
+			pp.paragraphs[p.Number] = p
+			pp.inOrder = append(pp.inOrder, p)
+			if emit != nil {
+				emit(p)
+			}
+		}
+		pp.duplicates = cp.Duplicates
+		vp.state = parseState{inBrief: cp.InBrief, loc: cp.Location.location()}
+	}
+
+	// With --prefetch, fetch the pages each page links to while the crawl is busy
+	prefetch := newPrefetcher(ctx, *prefetchWorkers)
+	defer prefetch.stop()
+
+	// Get the first page of the Catechism
+	for {
+		if ctx.Err() != nil {
+			return nil, pages, true, errInterrupted
+		}
+		prefetch.wait(urlStr)
+		doc, size, err := getSizedPage(ctx, urlStr)
+		var badCache *cacheError
+		if errors.As(err, &badCache) {
+			return nil, pages, true, err
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, pages, true, errInterrupted
+			}
+			// Skip the page rather than give up on the whole crawl. It isn't
+			// cached, so crawling again will try it again.
+			fmt.Fprintf(os.Stderr, "warning: skipping a page: %s\n", err)
+			failed = append(failed, urlStr)
+			visited[urlStr] = true
+			next := nextKnownPage(links, urlStr, visited)
+			plan = append(plan, crawledPage{URL: urlStr, Next: next, Failed: true})
+			if next == "" {
+				break
+			}
+			urlStr = next
+			continue
+		}
+		if cr.LinkedPages != nil {
+			linked := cr.LinkedPages(doc)
+			links = append(links, linked...)
+			prefetch.add(linked)
+		}
+		// Extract Paragraphs from doc
+		found := pp.parse(doc, urlStr, size)
+		visited[urlStr] = true
+		pages++
+		// Get next link
+		next := cr.NextPage(doc, visited)
+		page := crawledPage{URL: urlStr, Next: next, Paragraphs: found, Bytes: size}
+		if next == "" {
+			// Not visited, the "Next" link may go back to a page already crawled
+			page.Loop = cr.NextPage(doc, nil)
+		}
+		plan = append(plan, page)
+		if next == "" {
+			break
+		}
+		if cr.MaxPages > 0 && pages >= cr.MaxPages {
+			partial = true
+			break
+		}
+		urlStr = next
+		if resumable {
+			saveCheckpoint(newCheckpoint(cr.Start, next, pages, visited, failed, pp.inOrder, pp.duplicates, vp.state))
+		}
+	}
+	if resumable {
+		removeCheckpoint()
+	}
+
+	if len(failed) > 0 {
+		fmt.Fprintf(os.Stderr, "couldn't fetch %d pages, crawl again to retry them:\n", len(failed))
+		for _, urlStr := range failed {
+			fmt.Fprintf(os.Stderr, "  %s\n", urlStr)
+		}
+	}
+
+	partial = partial || cr.Partial
+	c = pp.catechism(!cr.Partial)
+	c.failedPages = failed
+	c.plan = plan
+	c.lang = cr.Lang
+	if err := strictError(c, !partial); err != nil {
+		return nil, pages, partial, err
+	}
+	return c, pages, partial, nil
+}
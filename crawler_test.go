@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// fakeArchive serves pages from memory, each one's body keyed by its URL, so a
+// crawl never touches the network or the cache directory, except for its
+// checkpoint, which goes in a temporary directory
+func fakeArchive(t *testing.T, pages map[string]string) {
+	t.Helper()
+	cache, cacheDir := pageCache, versionedCacheDir
+	t.Cleanup(func() { pageCache, versionedCacheDir = cache, cacheDir })
+	useMemoryCache()
+	versionedCacheDir = t.TempDir()
+	for urlStr, body := range pages {
+		dumped := fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: text/html; charset=utf-8\r\nContent-Length: %d\r\n\r\n%s", len(body), body)
+		if err := pageCache.Put(urlStr, []byte(dumped)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// fakeNextPage follows a fixed list of pages instead of the pages' "Next" links
+func fakeNextPage(order ...string) func(*goquery.Document, map[string]bool) string {
+	return func(_ *goquery.Document, visited map[string]bool) string {
+		for _, urlStr := range order {
+			if !visited[urlStr] {
+				return urlStr
+			}
+		}
+		return ""
+	}
+}
+
+func TestCrawlFromStartPage(t *testing.T) {
+	fakeArchive(t, map[string]string{
+		"https://example.org/2": "<p>484 The Annunciation to Mary inaugurates the fullness of time.</p>",
+		"https://example.org/3": "<p>PART TWO THE CELEBRATION OF THE CHRISTIAN MYSTERY</p><p>1066 In the Symbol of the faith the Church confesses the mystery.</p>",
+	})
+	// A checkpoint left by an interrupted full crawl
+	checkpoint := filepath.Join(versionedCacheDir, checkpointFile)
+	saved := `{"first_page":"https://example.org/1","next":"https://example.org/2"}`
+	if err := os.WriteFile(checkpoint, []byte(saved), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	crawler := &Crawler{
+		Start:      "https://example.org/2",
+		NextPage:   fakeNextPage("https://example.org/2", "https://example.org/3"),
+		Parser:     NewVaticanParser(),
+		Checkpoint: true,
+		Partial:    true,
+	}
+	c, pages, partial := crawler.Crawl(context.Background(), nil)
+	if pages != 2 || len(c.Paragraphs) != 2 {
+		t.Errorf("crawled %d pages and found %d paragraphs, want 2 and 2", pages, len(c.Paragraphs))
+	}
+	// Partial however far it went, even though it ran out of pages
+	if !partial {
+		t.Error("a crawl from a start page isn't partial")
+	}
+	// The full crawl's checkpoint is left for it to resume from
+	if data, err := os.ReadFile(checkpoint); err != nil || string(data) != saved {
+		t.Errorf("the checkpoint is %q (%v), want it left as %q", data, err, saved)
+	}
+	// The paragraphs before the first heading aren't in the Prologue
+	if part := c.Paragraphs[484].levels().Part; part != "" {
+		t.Errorf("paragraph 484 is in the part %q, want none", part)
+	}
+	if part := c.Paragraphs[1066].levels().Part; !strings.HasPrefix(part, "PART TWO") {
+		t.Errorf("paragraph 1066 is in the part %q, want PART TWO", part)
+	}
+}
+
+func TestCrawlFromFirstPage(t *testing.T) {
+	fakeArchive(t, map[string]string{
+		"https://example.org/1": "<p>1 God, infinitely perfect and blessed in himself, in a plan of sheer goodness.</p>",
+		"https://example.org/2": "<p>PART ONE THE PROFESSION OF FAITH</p><p>26 We begin our profession of faith by saying: I believe.</p>",
+	})
+	crawler := &Crawler{
+		Start:    "https://example.org/1",
+		NextPage: fakeNextPage("https://example.org/1", "https://example.org/2"),
+		Parser:   NewVaticanParser(),
+	}
+	c, _, partial := crawler.Crawl(context.Background(), nil)
+	if partial {
+		t.Error("a crawl that ran out of pages is partial")
+	}
+	if part := c.Paragraphs[1].levels().Part; part != prologueTitle {
+		t.Errorf("paragraph 1 is in the part %q, want the %s", part, prologueTitle)
+	}
+}
+
+func TestCrawlResumesFailedPages(t *testing.T) {
+	fakeArchive(t, map[string]string{
+		"https://example.org/1": "<p>1 God, infinitely perfect and blessed in himself, in a plan of sheer goodness.</p>",
+		"https://example.org/3": "<p>3 Those who with God's help have welcomed Christ's call.</p>",
+		"https://example.org/4": "<p>4 Quite early on, the name catechesis was given.</p>",
+	})
+	// Page 2 can't be fetched
+	client, retries := httpClient, *fetchRetries
+	t.Cleanup(func() { httpClient, *fetchRetries = client, retries })
+	httpClient = &http.Client{Transport: slowTransport{}}
+	*fetchRetries = 0
+
+	order := []string{"https://example.org/1", "https://example.org/2", "https://example.org/3", "https://example.org/4"}
+	newCrawler := func() *Crawler {
+		return &Crawler{
+			Start:       order[0],
+			NextPage:    fakeNextPage(order...),
+			LinkedPages: func(*goquery.Document) []string { return order },
+			Parser:      NewVaticanParser(),
+			Checkpoint:  true,
+		}
+	}
+	// Interrupted after page 3, once page 2 has been skipped
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, _, _, err := newCrawler().TryCrawl(ctx, func(p Paragraph) {
+		if p.Number == 3 {
+			cancel()
+		}
+	})
+	if err != errInterrupted {
+		t.Fatalf("the crawl returned %v, want it interrupted", err)
+	}
+	cp, ok := loadCheckpoint(order[0])
+	if !ok || cp.Next != order[3] || len(cp.Failed) != 1 || cp.Failed[0] != order[1] {
+		t.Fatalf("the checkpoint goes on from %q with the failed pages %q, want %q and %q", cp.Next, cp.Failed, order[3], order[1:2])
+	}
+
+	c, _, _ := newCrawler().Crawl(context.Background(), nil)
+	if len(c.failedPages) != 1 || c.failedPages[0] != order[1] {
+		t.Errorf("the resumed crawl's failed pages are %q, want %q", c.failedPages, order[1:2])
+	}
+	if len(c.Paragraphs) != 3 {
+		t.Errorf("the resumed crawl found %d paragraphs, want 3", len(c.Paragraphs))
+	}
+}
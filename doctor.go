@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// A known paragraph, and something its text has to contain, for checking the parser
+const (
+	doctorParagraph = 484
+	doctorText      = "Annunciation"
+)
+
+// doctor checks the things ccc needs to work, prints whether each one passed
+// with a hint on how to fix it if it didn't, and exits non-zero if any failed
+func doctor(ctx context.Context) {
+	ok := true
+	check := func(name string, err error, hint string) bool {
+		if err != nil {
+			fmt.Printf("[fail] %s: %s\n", name, err)
+			fmt.Printf("       %s\n", hint)
+			ok = false
+			return false
+		}
+		fmt.Printf("[ok]   %s\n", name)
+		return true
+	}
+
+	online := check("network: "+vatican+" is reachable", checkNetwork(ctx),
+		"check your internet connection, and HTTPS_PROXY if you need a proxy")
+	check("cache: "+versionedCacheDir+"/ is writable", checkCacheWritable(),
+		"run ccc from a directory you can write to, or use --no-cache")
+
+	index := loadPageIndex()
+	pages := cachedPages()
+	total := lastParagraph("en")
+	var err error
+	if len(index) == 0 {
+		err = fmt.Errorf("there is no page index yet")
+	} else if len(index) < total {
+		err = fmt.Errorf("only %d of %d paragraphs are indexed", len(index), total)
+	}
+	check(fmt.Sprintf("cache: %d pages cached, and all %d paragraphs are indexed", pages, total), err,
+		"run `ccc crawl` to crawl the whole Catechism and index it")
+
+	// The parser can only be checked from the cache, or with the network
+	if urlStr, found := pageFor(index, doctorParagraph); found {
+		if _, cached := pageCache.Get(urlStr); cached || online {
+			check(fmt.Sprintf("parser: paragraph %d contains %q", doctorParagraph, doctorText), checkParser(ctx),
+				"the Vatican's pages may have changed, try `ccc cache clear` and `ccc selftest`")
+		}
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// checkNetwork makes a HEAD request for the first page of the catechism
+func checkNetwork(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	res, err := httpClient.Do(newRequest(ctx, "HEAD", vaticanFirstPage))
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", vaticanFirstPage, res.Status)
+	}
+	return nil
+}
+
+// checkCacheWritable creates and removes a file in the cache directory
+func checkCacheWritable() error {
+	if *noCache {
+		return fmt.Errorf("--no-cache is set")
+	}
+	if err := os.MkdirAll(versionedCacheDir, 0755); err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(versionedCacheDir, ".doctor-*")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// cachedPages returns how many pages are in the cache directory
+func cachedPages() int {
+	entries, err := ioutil.ReadDir(versionedCacheDir)
+	if err != nil {
+		return 0
+	}
+	n := 0
+	for _, entry := range entries {
+		if strings.HasSuffix(strings.ToUpper(entry.Name()), ".HTM") {
+			n++
+		}
+	}
+	return n
+}
+
+// checkParser looks up the known paragraph and checks its text
+func checkParser(ctx context.Context) error {
+	p, found := findParagraph(ctx, doctorParagraph)
+	if !found {
+		return fmt.Errorf("paragraph %d wasn't found on the page the index says it's on", doctorParagraph)
+	}
+	if !strings.Contains(p.Text, doctorText) {
+		return fmt.Errorf("paragraph %d is %q", doctorParagraph, snippet(compactText(p.Text)))
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var explainParse = flag.Bool("explain", false, "instead of printing paragraphs, show how each of them was parsed")
+
+// explain prints what the parser did with each paragraph in args: where it
+// came from, the HTML it was parsed from and the references found in it
+func explain(ctx context.Context, args []string) {
+	var nums []int
+	for _, arg := range args {
+		specNums, err := parseParagraphSpec(arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(2)
+		}
+		nums = append(nums, specNums...)
+	}
+	if len(nums) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: ccc <number>... --explain")
+		os.Exit(2)
+	}
+	ok := true
+	for i, num := range nums {
+		p, found := findParagraph(ctx, num)
+		if !found {
+			p, found = getCatechism(ctx)[num]
+		}
+		if !found {
+			fmt.Fprintf(os.Stderr, "paragraph %d not found\n", num)
+			ok = false
+			continue
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		explainParagraph(ctx, p)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// explainParagraph prints the parsing decisions for p
+func explainParagraph(ctx context.Context, p Paragraph) {
+	fmt.Printf("source:     %s\n", p.SourceURL)
+
+	// Find the elements on the page that start with the number. The parser
+	// keeps the one --dedup-policy picks and never joins text across elements.
+	var elements []*goquery.Selection
+	getPage(ctx, p.SourceURL).Find(*paragraphSelector).Each(func(_ int, s *goquery.Selection) {
+		if num, end, ok := extractRange(s.Text()); ok && num == p.Number && end == num {
+			elements = append(elements, s)
+		}
+	})
+	kept := 0
+	for i := 1; i < len(elements); i++ {
+		if replacesDuplicate(Paragraph{Text: elements[kept].Text()}, Paragraph{Text: elements[i].Text()}) {
+			kept = i
+		}
+	}
+	if len(elements) > 0 {
+		if html, err := goquery.OuterHtml(elements[kept]); err == nil {
+			fmt.Printf("html:       %s\n", html)
+		}
+	}
+	fmt.Printf("number:     %d, from the start of the text %q\n", p.Number, snippet(strings.TrimSpace(p.Text)))
+	switch len(elements) {
+	case 0, 1:
+		fmt.Println("merged:     no, the text is from a single <p>")
+	default:
+		fmt.Printf("merged:     no, but %d other <p> on the page also start with %d and were ignored (--dedup-policy %s kept <p> %d of %d)\n",
+			len(elements)-1, p.Number, *dedupPolicy, kept+1, len(elements))
+	}
+
+	refs := classifyReferences(p.Text)
+	if len(refs) == 0 {
+		fmt.Println("references: none")
+	}
+	for i, ref := range refs {
+		label := "references:"
+		if i > 0 {
+			label = ""
+		}
+		kind := ref.Kind
+		if kind == "" {
+			kind = "ignored"
+		}
+		fmt.Printf("%-11s %q -> %s %v (%s)\n", label, ref.Raw, kind, ref.Refs, ref.Rule)
+	}
+}
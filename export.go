@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+)
+
+var exportHTML = flag.Bool("html", false, "export as a single, self contained HTML file")
+var outputFile = flag.String("output", "", "write to `file` instead of stdout")
+var outputEncoding = flag.String("encoding", "utf-8", "write exports to --output in `encoding`: utf-8, latin1 or windows-1252")
+var linkRefs = flag.Bool("link-refs", false, "with export --html or --split-dir, link the paragraph references in the text to the paragraphs they cite")
+var onUnmappable = flag.String("on-unmappable", "replace", "when a character can't be written in --encoding, `replace` it with ? or stop with an error")
+
+// The encodings --encoding accepts, besides utf-8
+var outputCharmaps = map[string]*charmap.Charmap{
+	"latin1":       charmap.ISO8859_1,
+	"iso-8859-1":   charmap.ISO8859_1,
+	"windows-1252": charmap.Windows1252,
+	"cp1252":       charmap.Windows1252,
+}
+
+// outputCharset returns the name of the charset the output is written in, for
+// the <meta charset> of an HTML export
+func outputCharset() string {
+	if *outputFile != "" {
+		switch outputCharmaps[strings.ToLower(*outputEncoding)] {
+		case charmap.ISO8859_1:
+			return "iso-8859-1"
+		case charmap.Windows1252:
+			return "windows-1252"
+		}
+	}
+	return "utf-8"
+}
+
+// export writes the whole catechism in the format chosen by the export flags
+func export(c *Catechism) {
+	c = c.numberRange()
+	var write func(io.Writer) error
+	switch {
+	case *splitDir != "":
+		// Written below, one file per paragraph
+	case *exportHTML:
+		write = c.ExportHTML
+	case *exportGraphviz:
+		write = c.ExportGraphviz
+	case *exportJSONGraph:
+		write = c.ExportJSONGraph
+	case *exportAnki:
+		write = c.ExportAnki
+	case *exportSQL:
+		write = c.ExportSQL
+	default:
+		fmt.Fprintln(os.Stderr, "usage: ccc export --html|--graphviz|--json-graph|--anki|--sql|--split-dir dir [--with-scripture] [--output file]")
+		exit(2)
+	}
+	var hash string
+	if *ifNewer {
+		if *outputFile == "" && *splitDir == "" {
+			fmt.Fprintln(os.Stderr, "--if-newer needs --output or --split-dir, to know where the last export went")
+			exit(2)
+		}
+		hash = c.exportHash()
+		if unchangedSinceLastExport(hash) {
+			fmt.Fprintln(os.Stderr, "no changes since the last export")
+			return
+		}
+	}
+	start := time.Now()
+	var err error
+	if write == nil {
+		err = c.ExportSplit(*splitDir)
+	} else {
+		w, closeOutput := openEncodedOutput()
+		err = write(w)
+		if closeErr := closeOutput(); err == nil {
+			err = closeErr
+		}
+	}
+	if err == nil && *ifNewer {
+		err = saveExportHash(hash)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error exporting: %s\n", err)
+		exit(1)
+	}
+	verbosef("exported %d paragraphs in %s", len(c.Paragraphs), time.Since(start).Round(time.Millisecond))
+}
+
+// openOutput returns the file named by --output, or stdout, and a function to close it
+func openOutput() (io.Writer, func() error) {
+	if *outputFile == "" {
+		return os.Stdout, func() error { return nil }
+	}
+	file, err := os.Create(*outputFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error creating %s: %s\n", *outputFile, err)
+		exit(1)
+	}
+	return file, file.Close
+}
+
+// openEncodedOutput is like openOutput, but a file is written in --encoding
+func openEncodedOutput() (io.Writer, func() error) {
+	var t transform.Transformer
+	switch enc := strings.ToLower(*outputEncoding); enc {
+	case "utf-8", "utf8":
+	default:
+		cm, ok := outputCharmaps[enc]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown encoding %q, expected utf-8, latin1 or windows-1252\n", *outputEncoding)
+			exit(2)
+		}
+		switch *onUnmappable {
+		case "replace":
+			t = transform.Chain(runes.Map(func(r rune) rune {
+				if _, ok := cm.EncodeRune(r); !ok {
+					return '?'
+				}
+				return r
+			}), cm.NewEncoder())
+		case "error":
+			t = cm.NewEncoder()
+		default:
+			fmt.Fprintf(os.Stderr, "--on-unmappable must be replace or error, not %q\n", *onUnmappable)
+			exit(2)
+		}
+	}
+	w, closeOutput := openOutput()
+	// Stdout is left alone, the terminal decides how to show it
+	if t == nil || *outputFile == "" {
+		return w, closeOutput
+	}
+	encoded := transform.NewWriter(w, t)
+	return encoded, func() error {
+		err := encoded.Close()
+		if closeErr := closeOutput(); err == nil {
+			err = closeErr
+		}
+		return err
+	}
+}
+
+// ExportHTML writes the catechism as one HTML page, with a table of contents,
+// an anchor for every paragraph (#p484) and links between cross-referenced paragraphs.
+// The page is written as the tree is walked, it's never all in memory at once.
+func (c *Catechism) ExportHTML(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if err := htmlTemplate.Execute(bw, c); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// paragraphView is what the "paragraph" template needs, since it links to other paragraphs
+type paragraphView struct {
+	C *Catechism
+	P Paragraph
+}
+
+var htmlTemplate = template.Must(template.New("html").Funcs(template.FuncMap{
+	"args": func(c *Catechism, p Paragraph) paragraphView {
+		return paragraphView{C: c, P: p}
+	},
+	"charset": outputCharset,
+	// text returns the text of p as it's displayed, see displayed, with
+	// --link-refs linking its paragraph references to their anchors
+	"text": func(c *Catechism, p Paragraph) template.HTML {
+		return htmlText(c, compactText(displayed(p).Text))
+	},
+	// points returns the points of p as they're displayed, like text. A point
+	// can start with a number that's part of it, like "1 Cor", so only its
+	// spaces are collapsed, unlike a paragraph's text, which starts with its number.
+	"points": func(c *Catechism, p Paragraph) []template.HTML {
+		var points []template.HTML
+		for _, point := range p.Points {
+			text := displayed(Paragraph{Text: point}).Text
+			points = append(points, htmlText(c, strings.Join(strings.Fields(text), " ")))
+		}
+		return points
+	},
+	// reference returns the paragraph a reference like "485" points to, if it is in the catechism
+	"reference": func(c *Catechism, ref string) (int, error) {
+		num, err := strconv.Atoi(ref)
+		if err != nil {
+			return 0, nil
+		}
+		if _, ok := c.Paragraphs[num]; !ok {
+			return 0, nil
+		}
+		return num, nil
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="{{charset}}">
+<title>Catechism of the Catholic Church</title>
+<style>
+body { margin: 0; font-family: Georgia, serif; line-height: 1.5; color: #222; }
+nav { position: fixed; top: 0; bottom: 0; left: 0; width: 20em; overflow-y: auto; padding: 1em; background: #f4f1ea; font-size: 0.85em; }
+nav ul { list-style: none; padding-left: 1em; margin: 0; }
+nav a { color: #333; text-decoration: none; }
+nav a:hover { text-decoration: underline; }
+main { margin-left: 22em; max-width: 45em; padding: 1em 2em; }
+.number { font-weight: bold; margin-right: 0.5em; }
+.number a { color: inherit; text-decoration: none; }
+.references { font-size: 0.85em; color: #666; }
+</style>
+</head>
+<body>
+<nav>
+<ul>
+{{- range $pi, $part := .Parts}}
+<li><a href="#part{{$pi}}">{{$part.Title}}</a>
+<ul>
+{{- range $si, $section := $part.Sections}}
+<li>{{if $section.Title}}<a href="#part{{$pi}}-{{$si}}">{{$section.Title}}</a>{{end}}
+<ul>
+{{- range $ci, $chapter := $section.Chapters}}
+<li>{{if $chapter.Title}}<a href="#part{{$pi}}-{{$si}}-{{$ci}}">{{$chapter.Title}}</a>{{end}}
+<ul>
+{{- range $ai, $article := $chapter.Articles}}{{if $article.Title}}
+<li><a href="#part{{$pi}}-{{$si}}-{{$ci}}-{{$ai}}">{{$article.Title}}</a></li>{{end}}
+{{- end}}
+</ul>
+</li>
+{{- end}}
+</ul>
+</li>
+{{- end}}
+</ul>
+</li>
+{{- end}}
+</ul>
+</nav>
+<main>
+<h1>Catechism of the Catholic Church</h1>
+{{- range $pi, $part := .Parts}}
+<h2 id="part{{$pi}}">{{$part.Title}}</h2>
+{{- range $si, $section := $part.Sections}}
+{{- if $section.Title}}
+<h3 id="part{{$pi}}-{{$si}}">{{$section.Title}}</h3>
+{{- end}}
+{{- range $ci, $chapter := $section.Chapters}}
+{{- if $chapter.Title}}
+<h4 id="part{{$pi}}-{{$si}}-{{$ci}}">{{$chapter.Title}}</h4>
+{{- end}}
+{{- range $ai, $article := $chapter.Articles}}
+{{- if $article.Title}}
+<h5 id="part{{$pi}}-{{$si}}-{{$ci}}-{{$ai}}">{{$article.Title}}</h5>
+{{- end}}
+{{- range $article.SubArticles}}
+{{- if .Title}}
+<h6>{{.Title}}</h6>
+{{- end}}
+{{- range .Paragraphs}}
+{{template "paragraph" (args $ .)}}
+{{- end}}
+{{- end}}
+{{- end}}
+{{- end}}
+{{- end}}
+{{- end}}
+</main>
+</body>
+</html>
+{{define "paragraph"}}<p id="p{{.P.Number}}"><span class="number"><a href="#p{{.P.Number}}">{{.P.Number}}</a></span>{{text .C .P}}
+{{- if .P.References}}
+<br><span class="references">See
+{{- range .P.References}} {{with reference $.C .}}<a href="#p{{.}}">{{.}}</a>{{else}}{{.}}{{end}}{{end}}</span>
+{{- end}}</p>
+{{- with points .C .P}}
+<ul>
+{{- range .}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+{{- end}}{{end}}
+`))
+
+// htmlText escapes text, already on one line, for the HTML export, with
+// --link-refs linking its paragraph references to the anchors of the
+// paragraphs they cite
+func htmlText(c *Catechism, text string) template.HTML {
+	var b strings.Builder
+	for _, span := range referenceSpans(text) {
+		if num, ok := linkTarget(span.Nums, c.Paragraphs); ok && *linkRefs {
+			fmt.Fprintf(&b, `<a href="#p%d">%s</a>`, num, template.HTMLEscapeString(span.Text))
+		} else {
+			b.WriteString(template.HTMLEscapeString(span.Text))
+		}
+	}
+	return template.HTML(b.String())
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// A paragraph whose points start with numbers that are part of them
+var numberedPoints = Paragraph{
+	Number: 1822,
+	Text:   "1822 Charity is the theological virtue:",
+	Points: []string{"1 Cor 13:4-7 describes it,", "10   commandments sum it up."},
+}
+
+func TestExportPointsKeepLeadingNumbers(t *testing.T) {
+	c := &Catechism{Paragraphs: map[int]Paragraph{1822: numberedPoints}}
+	c.buildTree([]Paragraph{numberedPoints}, true)
+
+	var html, markdown, anki strings.Builder
+	if err := c.ExportHTML(&html); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeMarkdown(&markdown, numberedPoints, c.Paragraphs); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ExportAnki(&anki); err != nil {
+		t.Fatal(err)
+	}
+	exports := map[string]string{"html": html.String(), "anki": anki.String(), "markdown": markdown.String()}
+	for name, export := range exports {
+		for _, want := range []string{"1 Cor 13:4-7 describes it,", "10 commandments sum it up."} {
+			if !strings.Contains(export, want) {
+				t.Errorf("the %s export doesn't have the point %q:\n%s", name, want, export)
+			}
+		}
+		// The text still has its own number stripped
+		if strings.Contains(export, "1822 Charity") {
+			t.Errorf("the %s export has the paragraph's number in its text:\n%s", name, export)
+		}
+	}
+}
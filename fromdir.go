@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html/charset"
+)
+
+var fromDir = flag.String("from-dir", "", "parse the catechism from the __P*.HTM pages saved in `dir` instead of crawling")
+
+// The pages of the catechism in the Vatican's archive, like __P2.HTM or __P1A.HTM
+var reArchivePage = regexp.MustCompile(`(?i)^__P[0-9A-Z]+\.HTML?$`)
+
+// loadDir parses the pages of the catechism saved in dir, like a copy of the
+// Vatican's archive downloaded by hand. Instead of following "Next" links, the
+// pages are read in the order of their names: they're numbered in base 36, so
+// __P9.HTM comes before __PA.HTM, and __PZ.HTM before __P10.HTM.
+func loadDir(dir string) *Catechism {
+	c, err := tryLoadDir(dir)
+	if err != nil {
+		exitWithCrawlError(err)
+	}
+	return c
+}
+
+// tryLoadDir is loadDir, but returns an error instead of exiting
+func tryLoadDir(dir string) (*Catechism, error) {
+	pages, err := archivePages(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", dir, err)
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("there are no __P*.HTM pages in %s", dir)
+	}
+	pp := newPageParser(NewVaticanParser(), nil)
+	for _, page := range pages {
+		doc, size, err := readLocalPage(page)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", page, err)
+		}
+		pp.parse(doc, page, size)
+	}
+	c := pp.catechism(true)
+	if err := strictError(c, true); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// archivePages returns the paths of the catechism's pages in dir, in page order
+func archivePages(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && reArchivePage.MatchString(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	// Base 36 numbers sort by length, then by digit, and '0'-'9' are before 'A'-'Z'
+	sort.Slice(names, func(i, j int) bool {
+		a, b := pageNumber(names[i]), pageNumber(names[j])
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return a < b
+	})
+	paths := make([]string, len(names))
+	for i, name := range names {
+		paths[i] = filepath.Join(dir, name)
+	}
+	return paths, nil
+}
+
+// pageNumber returns the base 36 number in a page's name, like "1A" for __P1A.HTM
+func pageNumber(name string) string {
+	name = strings.ToUpper(name)
+	return strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(name, "__P"), ".HTML"), ".HTM")
+}
+
+// readLocalPage parses a saved HTML file, decoded using the charset in its <meta> tag
+func readLocalPage(path string) (*goquery.Document, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+	counted := &countingReader{r: file}
+	utf8Body, err := charset.NewReader(counted, "text/html")
+	if err != nil {
+		return nil, 0, fmt.Errorf("error decoding the page: %w", err)
+	}
+	doc, err := goquery.NewDocumentFromReader(utf8Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error creating new goquery doc: %w", err)
+	}
+	return doc, counted.n, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// A glossary entry starts with the term in capitals and a colon, like
+// "ABORTION: The deliberate termination of a pregnancy..."
+var reGlossaryEntry = regexp.MustCompile(`(?s)^\s*(\p{Lu}[\p{Lu}\p{Mn}'’ ,()/-]*[\p{Lu})]):\s*(.+)$`)
+
+// glossary prints the definitions of the term in args. The term can be in any case,
+// and if there's no such term, every term starting with it (or else containing it)
+// is printed instead.
+func glossary(ctx context.Context, args []string) {
+	term := strings.Join(args, " ")
+	if term == "" {
+		fmt.Fprintln(os.Stderr, "usage: ccc glossary <term>")
+		os.Exit(2)
+	}
+	entries := loadGlossary(ctx)
+	terms := matchGlossary(entries, term)
+	if len(terms) == 0 {
+		fmt.Fprintf(os.Stderr, "%q is not in the glossary\n", term)
+		os.Exit(1)
+	}
+	for i, t := range terms {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s: %s\n", t, entries[t])
+	}
+}
+
+// matchGlossary returns the terms matching term, sorted
+func matchGlossary(entries map[string]string, term string) []string {
+	want := fold(strings.TrimSpace(term))
+	var prefixed, contained []string
+	for t := range entries {
+		folded := fold(t)
+		if folded == want {
+			return []string{t}
+		}
+		if strings.HasPrefix(folded, want) {
+			prefixed = append(prefixed, t)
+		} else if strings.Contains(folded, want) {
+			contained = append(contained, t)
+		}
+	}
+	terms := prefixed
+	if len(terms) == 0 {
+		terms = contained
+	}
+	sort.Strings(terms)
+	return terms
+}
+
+// loadGlossary crawls the glossary pages, which come after the numbered
+// paragraphs and are linked from the index, and returns each term's definition
+func loadGlossary(ctx context.Context) map[string]string {
+	urlStr, found := glossaryPage(ctx)
+	if !found {
+		fmt.Fprintln(os.Stderr, "the index doesn't link to a glossary")
+		os.Exit(1)
+	}
+	entries := make(map[string]string)
+	visited := make(map[string]bool)
+	for urlStr != "" {
+		visited[urlStr] = true
+		verbosef("reading glossary page %s", urlStr)
+		doc := getPage(ctx, urlStr)
+		n := parseGlossaryPage(doc, entries)
+		// The glossary is the last thing in the archive, so stop at the first page
+		// after it that has no entries
+		if n == 0 && len(entries) > 0 {
+			break
+		}
+		urlStr = getNextLink(doc, visited)
+	}
+	return entries
+}
+
+// glossaryPage returns the URL of the first glossary page, found by the link text on the index
+func glossaryPage(ctx context.Context) (string, bool) {
+	var page string
+	getPage(ctx, vaticanIndexPage).Find("a").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		if !strings.Contains(fold(s.Text()), "glossary") {
+			return true
+		}
+		href, _ := s.Attr("href")
+		urlStr, err := vaticanURL(href)
+		if err != nil {
+			return true
+		}
+		page, _, _ = strings.Cut(urlStr, "#")
+		return false
+	})
+	return page, page != ""
+}
+
+// parseGlossaryPage adds the entries on doc to entries and returns how many there were
+func parseGlossaryPage(doc *goquery.Document, entries map[string]string) int {
+	n := 0
+	doc.Find("p").Each(func(_ int, s *goquery.Selection) {
+		m := reGlossaryEntry.FindStringSubmatch(s.Text())
+		if m == nil {
+			return
+		}
+		entries[strings.Join(strings.Fields(m[1]), " ")] = strings.Join(strings.Fields(m[2]), " ")
+		n++
+	})
+	return n
+}
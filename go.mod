@@ -3,7 +3,8 @@ module tobilehman.com/ccc
 go 1.18
 
 require (
-	github.com/PuerkitoBio/goquery v1.8.1 // indirect
-	github.com/andybalholm/cascadia v1.3.1 // indirect
-	golang.org/x/net v0.7.0 // indirect
+	github.com/PuerkitoBio/goquery v1.8.1
+	github.com/andybalholm/cascadia v1.3.1
+	golang.org/x/net v0.7.0
+	golang.org/x/text v0.7.0
 )
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	exportGraphviz  = flag.Bool("graphviz", false, "export the cross-references between paragraphs as a Graphviz dot file")
+	exportJSONGraph = flag.Bool("json-graph", false, "export the cross-references between paragraphs as JSON nodes and edges")
+	withScripture   = flag.Bool("with-scripture", false, "include scripture references in the graph export")
+)
+
+// A graphNode is a paragraph, or with --with-scripture, a scripture reference
+type graphNode struct {
+	ID   string `json:"id"`
+	Type string `json:"type"` // "paragraph" or "scripture"
+}
+
+// A graphEdge points from a paragraph to something it cites
+type graphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type graph struct {
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+}
+
+// referencedNumbers returns the paragraph numbers in a reference like "485" or
+// "484-486", or nil if it isn't one or goes past the last paragraph
+func referencedNumbers(ref string) []int {
+	from, to, isRange := strings.Cut(strings.ReplaceAll(ref, "–", "-"), "-")
+	start, err := strconv.Atoi(from)
+	if err != nil {
+		return nil
+	}
+	end := start
+	if isRange {
+		if end, err = strconv.Atoi(to); err != nil || end < start {
+			return nil
+		}
+	}
+	if start < 1 || end > lastParagraph("en") {
+		return nil
+	}
+	var nums []int
+	for n := start; n <= end; n++ {
+		nums = append(nums, n)
+	}
+	return nums
+}
+
+// graph returns every paragraph as a node, and an edge for each reference, in paragraph order
+func (c *Catechism) graph() graph {
+	var g graph
+	seen := make(map[string]bool)
+	addNode := func(id, typ string) {
+		if !seen[typ+" "+id] {
+			seen[typ+" "+id] = true
+			g.Nodes = append(g.Nodes, graphNode{ID: id, Type: typ})
+		}
+	}
+	for _, num := range sortedNumbers(c.Paragraphs) {
+		addNode(strconv.Itoa(num), "paragraph")
+	}
+	for _, num := range sortedNumbers(c.Paragraphs) {
+		p := c.Paragraphs[num]
+		from := strconv.Itoa(num)
+		for _, ref := range p.References {
+			for _, n := range referencedNumbers(ref) {
+				// References to paragraphs that weren't crawled still get a node
+				to := strconv.Itoa(n)
+				addNode(to, "paragraph")
+				g.Edges = append(g.Edges, graphEdge{From: from, To: to})
+			}
+		}
+		if *withScripture {
+			for _, ref := range p.ScriptureRefs {
+				addNode(ref, "scripture")
+				g.Edges = append(g.Edges, graphEdge{From: from, To: ref})
+			}
+		}
+	}
+	return g
+}
+
+// ExportGraphviz writes the cross-references as a directed graph in the dot language
+func (c *Catechism) ExportGraphviz(w io.Writer) error {
+	g := c.graph()
+	bw := bufio.NewWriter(w)
+	bw.WriteString("digraph ccc {\n")
+	for _, n := range g.Nodes {
+		if n.Type == "scripture" {
+			fmt.Fprintf(bw, "\t%q [shape=box];\n", n.ID)
+		} else {
+			fmt.Fprintf(bw, "\t%q;\n", n.ID)
+		}
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(bw, "\t%q -> %q;\n", e.From, e.To)
+	}
+	bw.WriteString("}\n")
+	// A bufio.Writer keeps the first error, so Flush reports any of them
+	return bw.Flush()
+}
+
+// ExportJSONGraph writes the cross-references as {"nodes": [...], "edges": [...]}
+func (c *Catechism) ExportJSONGraph(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(c.graph())
+}
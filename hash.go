@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ifNewer = flag.Bool("if-newer", false, "with export, skip the export if nothing changed since the last one to the same --output or --split-dir")
+
+// contentHash returns a SHA-256 hash of every paragraph, with its references
+// and where it is in the tree, in order
+func (c *Catechism) contentHash() string {
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	for _, num := range sortedNumbers(c.Paragraphs) {
+		p := c.Paragraphs[num]
+		enc.Encode(p)
+		enc.Encode(p.Location())
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// exportHash is the content hash along with every flag that was set, since an
+// export with different flags, like --encoding or --min-number, is a different export
+func (c *Catechism) exportHash() string {
+	settings := []string{c.contentHash()}
+	flag.Visit(func(f *flag.Flag) {
+		settings = append(settings, f.Name+"="+f.Value.String())
+	})
+	sum := sha256.Sum256([]byte(strings.Join(settings, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// hashFile returns where the hash of the last export to --output or --split-dir is kept
+func hashFile() string {
+	if *splitDir != "" {
+		return filepath.Join(*splitDir, ".ccc.sha256")
+	}
+	return *outputFile + ".sha256"
+}
+
+// unchangedSinceLastExport reports whether the export was already written with
+// the same content and flags, and its output is still there
+func unchangedSinceLastExport(hash string) bool {
+	last, err := ioutil.ReadFile(hashFile())
+	if err != nil || strings.TrimSpace(string(last)) != hash {
+		return false
+	}
+	output := *outputFile
+	if *splitDir != "" {
+		output = *splitDir
+	}
+	_, err = os.Stat(output)
+	return err == nil
+}
+
+// saveExportHash records the hash of an export that was just written
+func saveExportHash(hash string) error {
+	if err := ioutil.WriteFile(hashFile(), []byte(hash+"\n"), 0644); err != nil {
+		return fmt.Errorf("couldn't save the export's hash: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+var hyperlinksMode = flag.String("hyperlinks", "auto", "make references in printed paragraphs clickable: `auto` (on a terminal), always or never")
+
+// Set by setupHyperlinks from --hyperlinks
+var hyperlinks bool
+
+// The Bible scripture references are linked to, with the reference as the search
+const bibleURL = "https://www.biblegateway.com/passage/?version=RSVCE&search="
+
+// setupHyperlinks decides whether to print hyperlinks. It has to run before the
+// pager starts, since by then stdout isn't the terminal anymore.
+func setupHyperlinks() error {
+	switch *hyperlinksMode {
+	case "always":
+		hyperlinks = true
+	case "never":
+		hyperlinks = false
+	case "auto":
+		hyperlinks = isTerminal(os.Stdout) && os.Getenv("TERM") != "dumb"
+	default:
+		return fmt.Errorf("--hyperlinks must be auto, always or never, not %q", *hyperlinksMode)
+	}
+	return nil
+}
+
+// hyperlinked returns text with the references in it turned into OSC 8
+// terminal hyperlinks, if they're turned on: paragraph references link to the
+// Vatican page the paragraph is on, if the page index knows it, and scripture
+// references to the passage in an online Bible. Terminals that don't support
+// OSC 8 just show the text.
+func hyperlinked(text string) string {
+	if !hyperlinks {
+		return text
+	}
+	return reParenthesized.ReplaceAllStringFunc(text, func(group string) string {
+		refs := classifyReferences(group)
+		pieces := strings.Split(group[1:len(group)-1], ";")
+		if len(refs) != len(pieces) {
+			return group
+		}
+		for i, ref := range refs {
+			target := referenceURL(ref)
+			if target == "" {
+				continue
+			}
+			// Keep the spaces around the reference outside of the link
+			trimmed := strings.TrimSpace(pieces[i])
+			start := strings.Index(pieces[i], trimmed)
+			pieces[i] = pieces[i][:start] + osc8(target, trimmed) + pieces[i][start+len(trimmed):]
+		}
+		return "(" + strings.Join(pieces, ";") + ")"
+	})
+}
+
+// referenceURL returns where a reference links to, or "" if it doesn't
+func referenceURL(ref classifiedRef) string {
+	switch ref.Kind {
+	case "scripture":
+		return bibleURL + url.QueryEscape(ref.Refs[0])
+	case "paragraph":
+		nums := referencedNumbers(ref.Refs[0])
+		if len(nums) == 0 {
+			return ""
+		}
+		if urlStr, ok := linkIndex()[nums[0]]; ok {
+			return urlStr
+		}
+	}
+	return ""
+}
+
+var (
+	linkIndexOnce sync.Once
+	linkPages     map[int]string
+)
+
+// linkIndex is the page index, loaded once for all the links
+func linkIndex() map[int]string {
+	linkIndexOnce.Do(func() {
+		linkPages = loadPageIndex()
+	})
+	return linkPages
+}
+
+// osc8 wraps text in an OSC 8 hyperlink to target
+func osc8(target, text string) string {
+	return "\x1b]8;;" + target + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// jsonSchema prints a JSON Schema for the paragraphs printed by --json, with the
+// parts of the tree they're in as definitions too. It's generated from the
+// structs by reflection, so it always matches what encoding/json writes.
+func jsonSchema() {
+	defs := make(map[string]interface{})
+	for _, v := range []interface{}{Paragraph{}, Part{}} {
+		schemaFor(reflect.TypeOf(v), defs)
+	}
+	schema := map[string]interface{}{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title":   "ccc paragraph",
+		"$ref":    "#/$defs/Paragraph",
+		"$defs":   defs,
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(schema); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing the schema: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// schemaFor returns the schema for values of type t, adding a definition to
+// defs for every struct type it comes across
+func schemaFor(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaFor(t.Elem(), defs)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem(), defs)}
+	// A nil slice or map is encoded as null
+	case reflect.Slice:
+		return map[string]interface{}{"type": []string{"array", "null"}, "items": schemaFor(t.Elem(), defs)}
+	case reflect.Map:
+		return map[string]interface{}{"type": []string{"object", "null"}, "additionalProperties": schemaFor(t.Elem(), defs)}
+	case reflect.Struct:
+		ref := map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+		if _, ok := defs[t.Name()]; ok {
+			return ref
+		}
+		// Added before the fields, so that a type that contains itself doesn't recurse forever
+		def := map[string]interface{}{"type": "object"}
+		defs[t.Name()] = def
+		properties := make(map[string]interface{})
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, omitempty, ok := jsonField(field)
+			if !ok {
+				continue
+			}
+			properties[name] = schemaFor(field.Type, defs)
+			if !omitempty {
+				required = append(required, name)
+			}
+		}
+		def["properties"] = properties
+		def["required"] = required
+		def["additionalProperties"] = false
+		return ref
+	}
+	return map[string]interface{}{}
+}
+
+// jsonField returns the name encoding/json gives field, and whether it's left
+// out when empty. ok is false if the field isn't encoded at all.
+func jsonField(field reflect.StructField) (name string, omitempty, ok bool) {
+	if field.PkgPath != "" {
+		return "", false, false
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, false
+	}
+	name, options, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	for _, option := range strings.Split(options, ",") {
+		if option == "omitempty" {
+			omitempty = true
+		}
+	}
+	return name, omitempty, true
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// These flags narrow down the paragraphs printed by the dump and by search
+var (
+	compact   = flag.Bool("compact", false, "dump paragraphs as sorted, tab separated `number\ttext` lines")
+	streaming = flag.Bool("stream", false, "dump paragraphs as they're crawled, in the order they're found")
+	briefOnly = flag.Bool("brief-only", false, "only show the \"IN BRIEF\" summary paragraphs")
+	// Only paragraphs that cite other paragraphs, see extractReferences
+	withReferences = flag.Bool("only-with-references", false, "only show paragraphs that reference other paragraphs")
+	minNumber      = flag.Int("min-number", 0, "only show paragraphs numbered `N` or higher, also applies to export")
+	maxNumber      = flag.Int("max-number", 0, "only show paragraphs numbered `N` or lower, also applies to export")
+	headCount      = flag.Int("head", 0, "only show the first `N` paragraphs")
+	tailCount      = flag.Int("tail", 0, "only show the last `N` paragraphs")
+	excludePattern = flag.String("exclude", "", "leave out paragraphs whose text matches the regular expression `pattern`")
+	numberRanges   = flag.Bool("ranges", false, "with numbers, collapse consecutive numbers into ranges, like 1-484, 486-2865")
+	resumeFrom     = flag.Int("resume-from", 0, "dump the paragraphs from number `n` on, to pick up reading where you left off")
+)
+
+// dump prints every paragraph, sorted by number, starting at --resume-from
+func dump(paragraphs map[int]Paragraph) {
+	var ps []Paragraph
+	for _, num := range sortedNumbers(paragraphs) {
+		if num >= *resumeFrom {
+			ps = append(ps, paragraphs[num])
+		}
+	}
+	for _, p := range limitParagraphs(filterParagraphs(ps)) {
+		printDumped(p)
+	}
+}
+
+// streamDump is dump for --stream: it prints each paragraph as soon as the
+// crawl finds it, so --head and --tail, which need every paragraph, can't be used
+func streamDump(ctx context.Context) {
+	if *headCount > 0 || *tailCount > 0 {
+		fmt.Fprintln(os.Stderr, "--stream can't be used with --head or --tail")
+		os.Exit(2)
+	}
+	for p := range Stream(ctx) {
+		if p.Number >= *resumeFrom && len(filterParagraphs([]Paragraph{p})) > 0 {
+			printDumped(p)
+		}
+	}
+}
+
+func printDumped(p Paragraph) {
+	p = displayed(p)
+	if *compact {
+		fmt.Printf("%d\t%s\n", p.Number, compactText(p.Text))
+	} else {
+		fmt.Println(hyperlinked(wrapped(p.String())))
+	}
+}
+
+// numbers prints the number of every paragraph in the crawl, in order, one per
+// line or with --ranges as ranges on one line, so that gaps stand out
+func numbers(paragraphs map[int]Paragraph) {
+	var nums []int
+	for _, num := range sortedNumbers(paragraphs) {
+		if inNumberRange(num) {
+			nums = append(nums, num)
+		}
+	}
+	if *numberRanges {
+		fmt.Println(formatRanges(nums))
+		return
+	}
+	for _, num := range nums {
+		fmt.Println(num)
+	}
+}
+
+// firstOrLast prints the paragraph with the lowest number in the crawl, or with
+// last the highest, or with --only-numbers just its number
+func firstOrLast(paragraphs map[int]Paragraph, last bool) {
+	var nums []int
+	for _, num := range sortedNumbers(paragraphs) {
+		if inNumberRange(num) {
+			nums = append(nums, num)
+		}
+	}
+	if len(nums) == 0 {
+		fmt.Fprintln(os.Stderr, "no paragraphs were found")
+		exit(1)
+	}
+	num := nums[0]
+	if last {
+		num = nums[len(nums)-1]
+	}
+	if *onlyNumbers {
+		fmt.Println(num)
+		return
+	}
+	printParagraph(paragraphs[num])
+}
+
+// formatRanges formats sorted numbers as ranges of consecutive numbers, like "1-484, 486, 488-2865"
+func formatRanges(nums []int) string {
+	var ranges []string
+	for i := 0; i < len(nums); {
+		j := i
+		for j+1 < len(nums) && nums[j+1] == nums[j]+1 {
+			j++
+		}
+		if j == i {
+			ranges = append(ranges, strconv.Itoa(nums[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", nums[i], nums[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(ranges, ", ")
+}
+
+// filterParagraphs keeps the paragraphs that pass the filter flags, like --brief-only
+func filterParagraphs(ps []Paragraph) []Paragraph {
+	var kept []Paragraph
+	for _, p := range ps {
+		if *briefOnly && !p.InBrief {
+			continue
+		}
+		if *withReferences && len(p.References) == 0 {
+			continue
+		}
+		if !inNumberRange(p.Number) {
+			continue
+		}
+		if excluded(p) {
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept
+}
+
+// limitParagraphs applies --head or --tail to ps, which should already be sorted
+func limitParagraphs(ps []Paragraph) []Paragraph {
+	if *headCount > 0 && *headCount < len(ps) {
+		return ps[:*headCount]
+	}
+	if *tailCount > 0 && *tailCount < len(ps) {
+		return ps[len(ps)-*tailCount:]
+	}
+	return ps
+}
+
+// checkNumberRange returns an error if --min-number, --max-number and --resume-from don't make sense
+func checkNumberRange() error {
+	if *minNumber < 0 || *maxNumber < 0 {
+		return fmt.Errorf("--min-number and --max-number can't be negative")
+	}
+	if *resumeFrom < 0 {
+		return fmt.Errorf("--resume-from can't be negative")
+	}
+	if *maxNumber > 0 && *minNumber > *maxNumber {
+		return fmt.Errorf("--min-number %d is more than --max-number %d", *minNumber, *maxNumber)
+	}
+	return nil
+}
+
+// The compiled --exclude pattern, see compileExclude
+var reExclude *regexp.Regexp
+
+// compileExclude compiles --exclude. Like a --regex search, it ignores case and
+// accents unless --exact is set.
+func compileExclude() error {
+	if *excludePattern == "" {
+		return nil
+	}
+	pattern := *excludePattern
+	if !*exactSearch {
+		pattern = "(?i)" + stripMarks(pattern)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid --exclude: %w", err)
+	}
+	reExclude = re
+	return nil
+}
+
+// excluded reports whether p's text matches --exclude
+func excluded(p Paragraph) bool {
+	if reExclude == nil {
+		return false
+	}
+	text := p.Text
+	if !*exactSearch {
+		text = stripMarks(text)
+	}
+	return reExclude.MatchString(text)
+}
+
+// inNumberRange reports whether num is within --min-number and --max-number, when they're set
+func inNumberRange(num int) bool {
+	return num >= *minNumber && (*maxNumber == 0 || num <= *maxNumber)
+}
+
+// numberRange returns a copy of c with only the paragraphs within --min-number
+// and --max-number, or c itself if neither is set
+func (c *Catechism) numberRange() *Catechism {
+	if *minNumber == 0 && *maxNumber == 0 {
+		return c
+	}
+	kept := &Catechism{Paragraphs: make(map[int]Paragraph)}
+	var inOrder []Paragraph
+	for _, num := range sortedNumbers(c.Paragraphs) {
+		if inNumberRange(num) {
+			inOrder = append(inOrder, c.Paragraphs[num])
+		}
+	}
+	// The Prologue's paragraphs are already in it, the rest of a crawl from
+	// --start-page aren't in any part
+	kept.buildTree(inOrder, false)
+	return kept
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// The page index maps each paragraph number to the URL of the page it is on
+const pageIndexFile = "index.json"
+
+// loadPageIndex reads the page index from the cache directory,
+// or returns nil if there isn't one (or it can't be read)
+func loadPageIndex() map[int]string {
+	if *noCache {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(versionedCacheDir, pageIndexFile))
+	if err != nil {
+		return nil
+	}
+	// JSON object keys are strings, so the numbers are stored as strings
+	var stored map[string]string
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil
+	}
+	index := make(map[int]string, len(stored))
+	for key, urlStr := range stored {
+		num, err := strconv.Atoi(key)
+		if err != nil {
+			continue
+		}
+		index[num] = urlStr
+	}
+	return index
+}
+
+// buildPageIndex maps each paragraph number to the page it was parsed from
+func buildPageIndex(paragraphs map[int]Paragraph) map[int]string {
+	index := make(map[int]string, len(paragraphs))
+	for num, p := range paragraphs {
+		if p.SourceURL != "" {
+			index[num] = p.SourceURL
+		}
+	}
+	return index
+}
+
+// savePageIndex writes the page index to the cache directory for loadPageIndex
+func savePageIndex(index map[int]string) error {
+	if *noCache {
+		return nil
+	}
+	stored := make(map[string]string, len(index))
+	for num, urlStr := range index {
+		stored[strconv.Itoa(num)] = urlStr
+	}
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(versionedCacheDir, 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(versionedCacheDir, pageIndexFile), data)
+}
+
+// pageFor guesses which page paragraph num is on. If num is in the index that's
+// exact, otherwise it's the page of the closest lower numbered paragraph, since
+// pages hold runs of consecutive paragraphs.
+func pageFor(index map[int]string, num int) (string, bool) {
+	if urlStr, ok := index[num]; ok {
+		return urlStr, true
+	}
+	nums := make([]int, 0, len(index))
+	for n := range index {
+		nums = append(nums, n)
+	}
+	sort.Ints(nums)
+	// i is the position of the first number greater than num
+	i := sort.SearchInts(nums, num)
+	if i == 0 {
+		return "", false
+	}
+	return index[nums[i-1]], true
+}
+
+// findParagraph looks up a single paragraph by parsing only the page it is on,
+// instead of crawling the whole catechism. It returns false if the page index
+// doesn't exist or the paragraph wasn't on the expected page, in which case the
+// caller should fall back to a full crawl with getCatechism.
+func findParagraph(ctx context.Context, num int) (Paragraph, bool) {
+	// The page index is of the Vatican's pages, not the ones in --from-dir
+	if *fromDir != "" {
+		p, found := LoadShared(ctx).Paragraphs[num]
+		return p, found
+	}
+	index := loadPageIndex()
+	urlStr, ok := pageFor(index, num)
+	if !ok {
+		return Paragraph{}, false
+	}
+	doc, size, err := getSizedPage(ctx, urlStr)
+	if err != nil {
+		return Paragraph{}, false
+	}
+	return paragraphOnPage(doc, urlStr, size, num)
+}
+
+// paragraphOnPage parses the page doc at urlStr the way a crawl does, with the
+// Vatican's parser, and returns paragraph num from it. The paragraph is
+// normalized and deduplicated by --dedup-policy just like a crawled one. The
+// "IN BRIEF" state carried over from the previous page is unknown here, so a
+// paragraph at the very top of a page may not be tagged.
+func paragraphOnPage(doc *goquery.Document, urlStr string, size int64, num int) (Paragraph, bool) {
+	pp := newPageParser(NewVaticanParser(), nil)
+	pp.parse(doc, urlStr, size)
+	p, found := pp.paragraphs[num]
+	return p, found
+}
+
+// reindex crawls the catechism (from the cache where possible) and rebuilds the page index
+func reindex(ctx context.Context) {
+	if !*noCache {
+		os.Remove(filepath.Join(versionedCacheDir, pageIndexFile))
+	}
+	index := buildPageIndex(getCatechism(ctx))
+	pages := make(map[string]bool)
+	for _, urlStr := range index {
+		pages[urlStr] = true
+	}
+	fmt.Printf("indexed %d paragraphs on %d pages\n", len(index), len(pages))
+}
+
+// rawPage prints the HTML of the page paragraph num is on, exactly as it was
+// fetched, to --output or stdout. This is what the parser sees, for debugging.
+func rawPage(ctx context.Context, args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: ccc raw <number> [--output file]")
+		os.Exit(2)
+	}
+	num, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid paragraph number %q\n", args[0])
+		os.Exit(2)
+	}
+	urlStr, ok := loadPageIndex()[num]
+	if !ok {
+		// Without an index, crawl to find it, which also saves the index for next time
+		urlStr = getCatechism(ctx)[num].SourceURL
+	}
+	if urlStr == "" {
+		fmt.Fprintf(os.Stderr, "paragraph %d not found\n", num)
+		os.Exit(1)
+	}
+	// The cache holds the whole dumped response, so read past the headers to the body
+	body := getOnce(ctx, urlStr)
+	defer body.Close()
+	res, err := http.ReadResponse(bufio.NewReader(body), nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading cached response for %s: %s\n", urlStr, err)
+		os.Exit(1)
+	}
+	defer res.Body.Close()
+	w, closeOutput := openOutput()
+	_, err = io.Copy(w, res.Body)
+	if closeErr := closeOutput(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error writing %s: %s\n", urlStr, err)
+		os.Exit(1)
+	}
+}
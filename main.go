@@ -3,6 +3,10 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -10,13 +14,18 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"os"
-	"path"
-	"path/filepath"
+	"os/signal"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html/charset"
 )
 
 // There are four parts to the catechism
@@ -27,177 +36,697 @@ type Part struct {
 
 // A section has many chapters
 type Section struct {
+	Parent   *Part `json:"-"`
 	Title    string
 	Chapters []Chapter
 }
 
 // A chapter has many articles
 type Chapter struct {
-	Parent   *Section
+	Parent   *Section `json:"-"`
 	Title    string
 	Articles []Article
 }
 
 // An article has many sub-articles
 type Article struct {
-	Parent      *Chapter
+	Parent      *Chapter `json:"-"`
+	Number      int      // The 3 in "ARTICLE 3", these start again from 1 in every chapter
 	Title       string
 	SubArticles []SubArticle
 }
 
 // A sub-article has many paragraphs
 type SubArticle struct {
-	Parent     *Article
+	Parent     *Article `json:"-"`
 	Title      string
 	Paragraphs []Paragraph
 }
 
 // A paragraph has a number (e.g. 484) and text, as well as many
 type Paragraph struct {
-	Parent     *SubArticle
-	Number     int // Paragraph numbers like 484 would correspond to "CCC 484" which starts with 'The Annunciation to Mary inaugurates "the fullness of time"'
-	Text       string
-	References []string
+	Parent     *SubArticle `json:"-"`
+	Number     int         `json:"number"` // Paragraph numbers like 484 would correspond to "CCC 484" which starts with 'The Annunciation to Mary inaugurates "the fullness of time"'
+	Text       string      `json:"text"`
+	References []string    `json:"references,omitempty"` // Other paragraphs cited, like "485"
+	// Scripture cited, like "Lk 1:26-38". See extractReferences for how the two are told apart.
+	ScriptureRefs []string `json:"scripture_refs,omitempty"`
+	Points        []string `json:"points,omitempty"`     // The items of any lists in the paragraph, which Text leaves out
+	InBrief       bool     `json:"in_brief,omitempty"`   // Set for the summary paragraphs under an article's "IN BRIEF" heading
+	SourceURL     string   `json:"source_url,omitempty"` // The page this paragraph was parsed from
+	// Both kinds of references as they were written, like "cf. 485"
+	RawReferences []string `json:"raw_references,omitempty"`
+	// The number of the article the paragraph is in, or 0 if it isn't in a numbered article
+	ArticleNumber int `json:"article_number,omitempty"`
+
+	loc location // where the paragraph was found, used to build the tree
+	// The titles of the section and chapter the paragraph is in, set when the tree
+	// is built so that labels don't have to walk the Parent pointers
+	sectionTitle string
+	chapterTitle string
+}
+
+// String returns the paragraph as "<number>  <text>", all on one line with the
+// whitespace normalized. Text itself is kept as it was parsed.
+func (p Paragraph) String() string {
+	return fmt.Sprintf("%d  %s", p.Number, compactText(p.Text))
 }
 
 // This is the index of the official Catechism of the Catholic Church, in English
 const vatican = "https://www.vatican.va"
+
+// The host of vatican, whose pages are cached under their path alone, see urlToFilename
+const vaticanHost = "www.vatican.va"
+
 const archeng = "/archive/ENG0015"
 
 // This is the first page of the catechism
 var vaticanFirstPage, _ = vaticanURL("/__P2.HTM")
 
-func urlToFilename(urlStr string) string {
-	// Parse the URL
-	u, err := url.Parse(urlStr)
+// Build metadata, injected with -ldflags "-X main.version=... -X main.commit=... -X main.date=..."
+var (
+	version = "dev"
+	commit  = "dev"
+	date    = "dev"
+)
+
+// userAgent identifies this build of ccc to the Vatican's servers
+func userAgent() string {
+	return fmt.Sprintf("ccc/%s (+https://github.com/tlehman/ccc)", version)
+}
+
+// The English editio typica is numbered from 1 to 2865
+const totalParagraphs = 2865
+
+// Extra headers added to every outbound request, set with --header 'Name: Value'
+var requestHeaders = headerFlag{}
+
+var verbose = flag.Bool("verbose", false, "log what the crawler is doing to stderr")
+var jsonOutput = flag.Bool("json", false, "print paragraphs as JSON, one object per line")
+var readStdin = flag.Bool("stdin", false, "read whitespace separated paragraph numbers from stdin")
+var separator = flag.String("separator", "", "line printed between paragraphs when printing several of them (default a blank line)")
+var noSeparator = flag.Bool("no-separator", false, "print nothing between paragraphs")
+
+func init() {
+	flag.Var(requestHeaders, "header", "add a `Name: Value` header to every request (can be repeated)")
+}
+
+// headerFlag collects repeated --header flags into an http.Header
+type headerFlag http.Header
+
+func (h headerFlag) String() string {
+	var lines []string
+	for name, values := range h {
+		for _, value := range values {
+			lines = append(lines, name+": "+value)
+		}
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, ", ")
+}
+
+func (h headerFlag) Set(value string) error {
+	name, val, found := strings.Cut(value, ":")
+	name = strings.TrimSpace(name)
+	if !found || name == "" || strings.ContainsAny(name, " \t") {
+		return fmt.Errorf("malformed header %q, expected 'Name: Value'", value)
+	}
+	http.Header(h).Add(name, strings.TrimSpace(val))
+	return nil
+}
+
+// parseArgs parses flags wherever they appear in args, so that both
+// `ccc --header 'A: b' 484` and `ccc 484 --header 'A: b'` work,
+// and returns the positional arguments that remain
+func parseArgs(args []string) []string {
+	var positional []string
+	for {
+		// flag.CommandLine exits with a usage message on a bad flag
+		flag.CommandLine.Parse(args)
+		rest := flag.Args()
+		if len(rest) == 0 {
+			return positional
+		}
+		// everything after a "--" terminator is positional
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...)
+		}
+		args = rest
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// getOnce uses httputil.DumpResponse to store the response in the page cache,
+// so each page is only ever requested once (./cache/url is the filename by default).
+// The caller must close the returned reader.
+func getOnce(ctx context.Context, urlStr string) io.ReadCloser {
+	r, err := openPage(ctx, urlStr)
 	if err != nil {
-		fmt.Printf("error parsing url %s: %s", urlStr, err)
+		exitIfInterrupted(ctx)
+		fmt.Println(err)
 		os.Exit(1)
 	}
+	return r
+}
 
-	// Extract the path
-	path := u.Path
+// openPage is like getOnce, but returns an error if the page couldn't be fetched
+func openPage(ctx context.Context, urlStr string) (io.ReadCloser, error) {
+	// Stream straight from the cache when it can, rather than reading whole pages into memory
+	if streaming, ok := pageCache.(StreamingCache); ok {
+		if r, cached := streaming.Open(urlStr); cached {
+			atomic.AddInt64(&cacheHits, 1)
+			return r, nil
+		}
+	}
+	data, cached := pageCache.Get(urlStr)
+	if cached {
+		atomic.AddInt64(&cacheHits, 1)
+	} else {
+		atomic.AddInt64(&cacheMisses, 1)
+		if *precheck && !precheckPage(ctx, urlStr) {
+			// Carry on as if the page were empty, without caching anything for it
+			return ioutil.NopCloser(strings.NewReader(skippedPage)), nil
+		}
+		var err error
+		if data, err = fetchPage(ctx, urlStr); err != nil {
+			return nil, err
+		}
+		//fmt.Printf("cacheing %s/\n", urlStr)
+		// save the bytes to the cache so we don't have to request again
+		if err := pageCache.Put(urlStr, data); err != nil {
+			fmt.Printf("error caching %s: %s\n", urlStr, err)
+			os.Exit(1)
+		}
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
 
-	// Replace slashes with underscores and remove trailing slash
-	path = strings.TrimRight(strings.ReplaceAll(path, "/", "_"), "_")
+var fetchRetries = flag.Int("retries", 2, "retry a page that fails to download, or that the server fails to serve, up to `N` times")
 
-	// Remove any illegal characters using a regular expression
-	illegalChars := regexp.MustCompile(`[<>:"|?*]`)
-	path = illegalChars.ReplaceAllString(path, "")
+// fetch makes an HTTP GET request for urlStr and returns the dumped response
+func fetch(ctx context.Context, urlStr string) []byte {
+	body, err := fetchPage(ctx, urlStr)
+	if err != nil {
+		exitIfInterrupted(ctx)
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return body
+}
 
-	// Make the path safe for the filesystem
-	return filepath.Clean(path)
+// fetchPage is like fetch, but retries with --retries and returns an error
+// instead of exiting. Server errors (5xx) are failures too, so that an error
+// page never ends up in the cache in place of the real one.
+func fetchPage(ctx context.Context, urlStr string) ([]byte, error) {
+	var err error
+	for attempt := 0; ; attempt++ {
+		var body []byte
+		if body, err = fetchOnce(ctx, urlStr); err == nil {
+			return body, nil
+		}
+		if attempt >= *fetchRetries || ctx.Err() != nil {
+			return nil, err
+		}
+		// Back off for 1s, 2s, 4s, ... between attempts
+		wait := time.Second << attempt
+		verbosef("%s, retrying in %s", err, wait)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, err
+		}
+	}
 }
 
-// getOnce uses httputil.DumpResponse to store the response on disk,
-// then uses http.ReadResponse to read the response from disk (./cache/url is the filename)
-func getOnce(urlStr string) io.Reader {
-	// Check if cached url is in ./cache/url file
-	filename := fmt.Sprintf("cache/%s", urlToFilename(urlStr))
-	//fmt.Printf("filename = %s\n", filename)
-	_, err := os.Stat(filename)
+func fetchOnce(ctx context.Context, urlStr string) ([]byte, error) {
+	req := newRequest(ctx, "GET", urlStr)
+	urlFullStr := req.URL.String()
+	res, err := httpClient.Do(req)
 	if err != nil {
-		if os.IsNotExist(err) {
-			// file doesn't exist, make an HTTP GET request
-			var urlFullStr string = urlStr
-			if !strings.HasPrefix(urlStr, "http") {
-				urlFullStr, _ = vaticanURL(urlStr)
-			}
-			res, err := http.Get(urlFullStr)
-			if err != nil {
-				fmt.Printf("error getting url %s: %s\n", urlFullStr, err)
-				os.Exit(1)
-			}
-			// dump the response body to raw bytes for caching
-			body, err := httputil.DumpResponse(res, true)
-			if err != nil {
-				fmt.Printf("error dumping response: %\n", err)
-				os.Exit(1)
-			}
-			//fmt.Printf("cacheing %s/\n", urlStr)
-			// save the bytes to the ./cache folder so we don't have to request again
-			file, err := os.Create(filename)
-			if err != nil {
-				fmt.Printf("error creating cache file %s: %s\n", filename, err)
-				os.Exit(1)
-			}
-			defer file.Close()
-			file.Write(body)
-			defer res.Body.Close()
-		}
-	} else {
-		//fmt.Printf("fetching %s from cache\n", urlStr)
+		return nil, fmt.Errorf("error getting url %s: %w", urlFullStr, err)
 	}
-	// Open and read dumped response, and return the response
-	data, err := ioutil.ReadFile(filename)
+	defer closeBody(res)
+	if res.StatusCode >= 500 {
+		return nil, fmt.Errorf("error getting url %s: %s", urlFullStr, res.Status)
+	}
+	// dump the response body to raw bytes for caching
+	body, err := httputil.DumpResponse(res, true)
 	if err != nil {
-		fmt.Printf("error reading file %s: %s", filename, data)
+		return nil, fmt.Errorf("error dumping response: %w", err)
 	}
+	return body, nil
+}
 
-	return bufio.NewReader(bytes.NewReader(data))
+// newRequest builds a request for urlStr with the User-Agent and any --header flags set
+func newRequest(ctx context.Context, method, urlStr string) *http.Request {
+	var urlFullStr string = urlStr
+	if !strings.HasPrefix(urlStr, "http") {
+		urlFullStr, _ = vaticanURL(urlStr)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, urlFullStr, nil)
+	if err != nil {
+		fmt.Printf("error building request for url %s: %s\n", urlFullStr, err)
+		os.Exit(1)
+	}
+	req.Header.Set("User-Agent", userAgent())
+	for name, values := range requestHeaders {
+		req.Header[name] = values
+	}
+	return req
 }
 
-func getCatechism() map[int]Paragraph {
-	var urlStr string = vaticanFirstPage
-	var paragraphs map[int]Paragraph = make(map[int]Paragraph)
+// Every request goes to the same host, several at once with --prefetch. The
+// default transport only keeps 2 idle connections per host, so the others were
+// closed after each request and a new one, with a new TLS handshake, opened for
+// the next. This one keeps enough open for every prefetch worker to reuse one.
+var crawlTransport = newCrawlTransport()
 
-	// Get the first page of the Catechism
-	for {
-		body := getOnce(urlStr)
-		// Create a goquery document
-		doc, err := goquery.NewDocumentFromReader(body)
-		if err != nil {
-			fmt.Printf("error creating new goquery doc: %s", err)
-			os.Exit(1)
+func newCrawlTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = 32
+	t.MaxIdleConnsPerHost = 32
+	t.IdleConnTimeout = 90 * time.Second
+	return t
+}
+
+// The client for every request ccc makes
+var httpClient = &http.Client{Transport: crawlTransport}
+
+// closeBody reads what's left of a response's body before closing it, since a
+// connection is only reused once its last response has been read to the end
+func closeBody(res *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(res.Body, 1<<20))
+	res.Body.Close()
+}
+
+// What getOnce returns for a page that failed the precheck: an empty page, with no paragraphs or links
+const skippedPage = "HTTP/1.1 200 OK\r\nContent-Type: text/html\r\nContent-Length: 13\r\n\r\n<html></html>"
+
+var precheck = flag.Bool("precheck", false, "send a HEAD request before fetching each page, and skip pages that aren't HTML or don't return 200")
+
+// precheckPage makes a HEAD request for urlStr and reports whether it looks like a real
+// page: a 200 with an HTML content type. Redirects are followed, like they are for GET.
+func precheckPage(ctx context.Context, urlStr string) bool {
+	res, err := httpClient.Do(newRequest(ctx, "HEAD", urlStr))
+	if err != nil {
+		exitIfInterrupted(ctx)
+		fmt.Fprintf(os.Stderr, "warning: skipping %s: %s\n", urlStr, err)
+		return false
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "warning: skipping %s: HEAD returned %s\n", urlStr, res.Status)
+		return false
+	}
+	if contentType := res.Header.Get("Content-Type"); !strings.HasPrefix(strings.ToLower(contentType), "text/html") {
+		fmt.Fprintf(os.Stderr, "warning: skipping %s: the content type is %q, not HTML\n", urlStr, contentType)
+		return false
+	}
+	return true
+}
+
+// The "IN BRIEF" heading introduces the summary paragraphs at the end of an article
+var reInBrief = regexp.MustCompile(`^\s*IN BRIEF\s*$`)
+
+// exitIfInterrupted exits when ctx has been cancelled by SIGINT or SIGTERM
+func exitIfInterrupted(ctx context.Context) {
+	if ctx.Err() != nil {
+		exitWithCrawlError(errInterrupted)
+	}
+}
+
+// The error TryCrawl returns when it's stopped by SIGINT or SIGTERM
+var errInterrupted = errors.New("interrupted, stopping crawl (pages fetched so far are cached)")
+
+// exitWithCrawlError prints err, which stopped a crawl, and exits: with 130 if
+// it was interrupted, like a shell does, and 1 otherwise
+func exitWithCrawlError(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	if errors.Is(err, errInterrupted) {
+		os.Exit(130)
+	}
+	exit(1)
+}
+
+// A cacheError is a page in the cache that can't be read. Unlike a
+// page that can't be fetched, which the crawl skips and fetches again next
+// time, it's there to stay, so it stops the crawl.
+type cacheError struct {
+	url string
+	err error
+}
+
+func (e *cacheError) Error() string {
+	return fmt.Sprintf("error reading cached response for %s: %s (`ccc cache verify --prune` removes bad pages)", e.url, e.err)
+}
+
+func (e *cacheError) Unwrap() error {
+	return e.err
+}
+
+// parseState is what the parser needs to remember from one page to the next
+type parseState struct {
+	// "IN BRIEF" sections can continue onto the next page
+	inBrief bool
+	// The divisions of the tree we are in, which can also carry over between pages
+	loc location
+}
+
+// getPage fetches (or reads from the cache) a page of the catechism and parses it
+func getPage(ctx context.Context, urlStr string) *goquery.Document {
+	doc, _, err := getSizedPage(ctx, urlStr)
+	if err != nil {
+		exitIfInterrupted(ctx)
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return doc
+}
+
+// getSizedPage is like getPage, but also returns the size of the page's body in
+// bytes, and returns an error if the page couldn't be fetched, or a *cacheError
+// if the cached page couldn't be read
+func getSizedPage(ctx context.Context, urlStr string) (*goquery.Document, int64, error) {
+	body, err := openPage(ctx, urlStr)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer body.Close()
+	doc, _, size, err := readPage(body)
+	if err != nil {
+		return nil, 0, &cacheError{url: urlStr, err: err}
+	}
+	return doc, size, nil
+}
+
+// readPage parses a dumped response, as it's stored in the cache, and returns
+// the page along with the response's status code and the size of its body
+func readPage(r io.Reader) (*goquery.Document, int, int64, error) {
+	res, err := http.ReadResponse(bufio.NewReader(r), nil)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("not an HTTP response: %w", err)
+	}
+	defer res.Body.Close()
+	// Old archive pages can be in Windows-1252 or ISO-8859-1 rather than UTF-8, so decode
+	// using the charset from the Content-Type header or the page's <meta> tag
+	counted := &countingReader{r: res.Body}
+	utf8Body, err := charset.NewReader(counted, res.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, res.StatusCode, counted.n, fmt.Errorf("error decoding the page: %w", err)
+	}
+	// Create a goquery document
+	doc, err := goquery.NewDocumentFromReader(utf8Body)
+	if err != nil {
+		return nil, res.StatusCode, counted.n, fmt.Errorf("error creating new goquery doc: %w", err)
+	}
+	// The decoder and parser treat a body that ends early like one that ended normally
+	if res.ContentLength >= 0 && counted.n != res.ContentLength {
+		return nil, res.StatusCode, counted.n, fmt.Errorf("the page is truncated, it has %d of its %d bytes", counted.n, res.ContentLength)
+	}
+	return doc, res.StatusCode, counted.n, nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// The elements paragraphs and headings are in. --selector changes it, for when
+// the Vatican's markup changes before ccc does.
+var paragraphSelector = flag.String("selector", "p", "find paragraphs and headings in the elements matching the CSS `selector`")
+
+// checkSelector validates --selector, since goquery quietly matches nothing with a bad one
+func checkSelector() error {
+	if _, err := cascadia.Compile(*paragraphSelector); err != nil {
+		return fmt.Errorf("invalid --selector %q: %s", *paragraphSelector, err)
+	}
+	return nil
+}
+
+// parsePage extracts the numbered paragraphs of a page, in the order they appear
+func parsePage(doc *goquery.Document, urlStr string, state *parseState) []Paragraph {
+	var paragraphs []Paragraph
+	doc.Find(*paragraphSelector).Each(func(_ int, s *goquery.Selection) {
+		// Track whether we are under an "IN BRIEF" heading
+		if reInBrief.MatchString(s.Text()) {
+			state.inBrief = true
+			return
 		}
-		// Extract Paragraphs from doc
-		doc.Find("p").Each(func(_ int, s *goquery.Selection) {
-			// Check for paragraph number
-			num, startsWithNumber := extractNumber(s.Text())
-			_, isStoredInMap := paragraphs[num]
-			if startsWithNumber && !isStoredInMap {
-				paragraphs[num] = Paragraph{
-					Number: num,
-					Text:   s.Text(),
-				}
-			}
+		// Check for paragraph number
+		num, end, startsWithNumber := extractRange(s.Text())
+		if !startsWithNumber {
+			// Anything else might be the heading of a new part, section, chapter, etc.
+			state.heading(headingText(s))
+			return
+		}
+		if end != num {
+			// A range like "484-489" heads a group of paragraphs, it isn't paragraph 484
+			return
+		}
+		references, scripture, rawRefs := extractReferences(s.Text())
+		paragraphs = append(paragraphs, Paragraph{
+			Number:        num,
+			Text:          s.Text(),
+			References:    references,
+			ScriptureRefs: scripture,
+			RawReferences: rawRefs,
+			Points:        listItems(s),
+			InBrief:       state.inBrief,
+			SourceURL:     urlStr,
+			loc:           state.loc,
 		})
-		// Get next link
-		next := getNextLink(doc)
-		if next == nil {
-			//fmt.Printf("next is nil")
-			return paragraphs
-		} else {
-			// Get urlStr to nextLink
-			urlPath, _ := next.Attr("href")
-			urlStr, err = vaticanURL(urlPath)
-			if err != nil {
-				fmt.Printf("error generating vaticanURL from urlPath = %s\n", urlPath)
-			}
+	})
+	return paragraphs
+}
+
+// listItems returns the text of each list item in the paragraph s. A list can't
+// be inside a <p>, so the lists are the ones between s and the next paragraph.
+func listItems(s *goquery.Selection) []string {
+	var items []string
+	s.NextUntil(*paragraphSelector).Filter("ul, ol").Find("li").Each(func(_ int, li *goquery.Selection) {
+		if text := strings.Join(strings.Fields(li.Text()), " "); text != "" {
+			items = append(items, text)
 		}
-	}
+	})
+	return items
+}
+
+// getCatechism loads the catechism and returns its paragraphs by number
+func getCatechism(ctx context.Context) map[int]Paragraph {
+	return LoadShared(ctx).Paragraphs
 }
 
 func main() {
+	args := parseArgs(os.Args[1:])
+	if err := applyDefaults(); err != nil {
+		fmt.Fprintf(os.Stderr, "error reading settings: %s\n", err)
+		os.Exit(2)
+	}
+	if *noCache {
+		useMemoryCache()
+	} else if *hashedCache {
+		useHashedCache()
+	}
+	stopProfiling := startProfiling()
+	defer stopProfiling()
+	if *headCount > 0 && *tailCount > 0 {
+		fmt.Fprintln(os.Stderr, "--head and --tail can't be used together")
+		os.Exit(2)
+	}
+	if err := checkNumberRange(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := checkSelector(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := checkDedupPolicy(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := checkVerifyFlags(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := compileExclude(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := setupHyperlinks(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := setupWrap(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	// Cancel the crawl on Ctrl-C rather than dying in the middle of a request
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if len(args) > 0 && args[0] == "version" {
+		fmt.Printf("ccc %s (commit %s, built %s)\n", version, commit, date)
+		return
+	}
+	// selftest needs to run before anything is loaded, since it starts from an empty cache
+	if len(args) > 0 && args[0] == "selftest" {
+		selftest(ctx)
+		return
+	}
+	if len(args) > 0 && args[0] == "cache" {
+		cacheCommand(args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "compare" {
+		compare(ctx, args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "json-schema" {
+		jsonSchema()
+		return
+	}
+	if len(args) > 0 && args[0] == "doctor" {
+		doctor(ctx)
+		return
+	}
+	if len(args) > 0 && args[0] == "serve" {
+		serve(ctx)
+		return
+	}
+	if len(args) > 0 && args[0] == "glossary" {
+		glossary(ctx, args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "raw" {
+		rawPage(ctx, args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "crawl" {
+		crawlCommand(ctx)
+		return
+	}
+	if len(args) > 0 && args[0] == "reindex" {
+		reindex(ctx)
+		return
+	}
+	if *explainParse {
+		explain(ctx, args)
+		return
+	}
+	// A citation pasted without quotes, like `ccc CCC 484`, is split in two
+	if len(args) > 1 && strings.EqualFold(args[0], "CCC") {
+		args = append([]string{"CCC " + args[1]}, args[2:]...)
+	}
+	// A single paragraph can usually be found without crawling everything
+	if len(args) == 1 && !*readStdin {
+		if num, point, ok := parsePointSpec(args[0]); ok {
+			if p, found := findParagraph(ctx, num); found {
+				if !printPoint(p, point) {
+					os.Exit(1)
+				}
+				return
+			}
+		} else if nums, err := parseParagraphSpec(args[0]); err == nil && len(nums) == 1 {
+			num := nums[0]
+			if p, found := findParagraph(ctx, num); found {
+				if !printNumbers([]int{num}, map[int]Paragraph{num: p}) {
+					os.Exit(1)
+				}
+				return
+			}
+		}
+	}
+	// A streamed dump prints as it crawls, so it doesn't wait for the whole catechism
+	if *streaming && len(args) == 0 && !*readStdin {
+		streamDump(ctx)
+		return
+	}
 	// Load the Catechism into the Paragraph array
-	var paragraphs map[int]Paragraph = getCatechism()
+	catechism := LoadShared(ctx)
+	var paragraphs map[int]Paragraph = catechism.Paragraphs
+	// Start paging once the crawl is done, so its progress isn't sent to the pager
+	stopPager := startPager()
+	defer stopPager()
 	// Check for command arguments
-	if len(os.Args) > 1 {
-		reParNum := regexp.MustCompile(`(^\d+$)`)
+	if *readStdin {
+		if !printFromReader(os.Stdin, paragraphs) {
+			exit(1)
+		}
+	} else if len(args) > 0 {
 		reCommand := regexp.MustCompile(`^(begin|next|back)$`)
 
-		// Check if it's a paragram number
-		if reParNum.MatchString(os.Args[1]) {
-			paragraphNumber, err := strconv.Atoi(os.Args[1])
-			if err != nil {
-				fmt.Printf("error parsing 1st arg from os.Args: %s\n", err)
-				os.Exit(1)
+		if args[0] == "search" {
+			search(catechism, args[1:])
+		}
+		if args[0] == "verify" {
+			verify(catechism)
+		}
+		if args[0] == "export" {
+			export(catechism)
+		}
+		if args[0] == "path" {
+			for _, p := range paragraphsFromArgs(paragraphs, args[1:]) {
+				printLocation(p)
+			}
+		}
+		if args[0] == "cite" {
+			cite(paragraphs, args[1:])
+		}
+		if args[0] == "article" {
+			article(catechism, args[1:])
+		}
+		if args[0] == "neighbors" {
+			neighbors(catechism, args[1:])
+		}
+		if args[0] == "scripture" {
+			scripture(catechism, args[1:])
+		}
+		if args[0] == "brief" {
+			brief(catechism, args[1:])
+		}
+		if args[0] == "numbers" {
+			numbers(paragraphs)
+		}
+		if args[0] == "stats" {
+			stats(catechism)
+		}
+		if args[0] == "first" || args[0] == "last" {
+			firstOrLast(paragraphs, args[0] == "last")
+		}
+
+		// Check if it's a list of paragraph numbers, ranges or points, like "484" or "484-489 2865 487.2"
+		if isParagraphSpec(args[0]) || rePointSpec.MatchString(args[0]) {
+			// Nothing is printed unless they're all valid
+			for _, arg := range args {
+				if _, _, ok := parsePointSpec(arg); ok {
+					continue
+				}
+				if _, err := parseParagraphSpec(arg); err != nil {
+					fmt.Fprintf(os.Stderr, "%s\n", err)
+					exit(1)
+				}
+			}
+			ok := true
+			for _, arg := range args {
+				if !printSpec(arg, paragraphs) {
+					ok = false
+				}
+			}
+			if !ok {
+				exit(1)
 			}
-			fmt.Println(paragraphs[paragraphNumber].Text)
 		}
 		// Or if it's a subcommand like "begin"
-		if reCommand.MatchString(os.Args[1]) {
-			cmd := os.Args[1]
+		if reCommand.MatchString(args[0]) {
+			cmd := args[0]
 			if cmd == "begin" {
 				createPositionFile()
 			} else if cmd == "next" {
@@ -207,61 +736,374 @@ func main() {
 			}
 			// Now show the current position's paragraph:
 			pos := getPositionFileValue()
-			fmt.Println(paragraphs[pos].Text)
+			printParagraph(paragraphs[pos])
 		}
 
 	} else {
-		for _, p := range paragraphs {
-			text := strings.ReplaceAll(p.Text, "\n", " ")
-			fmt.Printf("%s\n", text)
+		dump(paragraphs)
+	}
+}
+
+// selftest clears the cache, crawls the whole catechism from scratch and checks
+// that every paragraph from 1 to the last one was found
+func selftest(ctx context.Context) {
+	start := time.Now()
+	// With --no-cache, the cache in memory is already empty
+	if !*noCache {
+		if err := clearCache(); err != nil {
+			fmt.Printf("error clearing cache: %s\n", err)
+			os.Exit(1)
 		}
+		fmt.Printf("cleared %s/\n", cacheDir)
 	}
+
+	paragraphs := getCatechism(ctx)
+	fmt.Printf("crawled %d paragraphs in %s\n", len(paragraphs), time.Since(start).Round(time.Millisecond))
+
+	missing, extra := findGaps(paragraphs, lastParagraph("en"))
+	if len(missing) > 0 {
+		fmt.Printf("missing %d paragraphs: %s\n", len(missing), joinNumbers(missing))
+	}
+	if len(extra) > 0 {
+		fmt.Printf("found %d unexpected paragraphs: %s\n", len(extra), joinNumbers(extra))
+	}
+	if len(missing) > 0 || len(extra) > 0 {
+		fmt.Println("FAIL")
+		os.Exit(1)
+	}
+	fmt.Println("PASS")
 }
 
-func getNextLink(doc *goquery.Document) *goquery.Selection {
-	var next *goquery.Selection = nil
-	doc.Find("a").Each(func(_ int, s *goquery.Selection) {
-		if s.Text() == "Next" {
-			next = s
-			return
+// findGaps returns the paragraph numbers from 1 to last that are missing, and
+// the numbers that were found outside of that range, both in ascending order
+func findGaps(paragraphs map[int]Paragraph, last int) (missing []int, extra []int) {
+	for num := 1; num <= last; num++ {
+		if _, ok := paragraphs[num]; !ok {
+			missing = append(missing, num)
+		}
+	}
+	for num := range paragraphs {
+		if num < 1 || num > last {
+			extra = append(extra, num)
 		}
+	}
+	sort.Ints(extra)
+	return missing, extra
+}
+
+func joinNumbers(nums []int) string {
+	strs := make([]string, len(nums))
+	for i, num := range nums {
+		strs[i] = strconv.Itoa(num)
+	}
+	return strings.Join(strs, ", ")
+}
+
+// The number of paragraphs printed so far, to know when a separator is needed
+var printedParagraphs int
+
+// verbosef logs a line to stderr, but only with --verbose
+func verbosef(format string, args ...interface{}) {
+	if *verbose {
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
+	}
+}
+
+// printParagraph prints a looked up paragraph as plain text, or as JSON with --json.
+// When several paragraphs are printed, the plain text ones are separated by --separator.
+func printParagraph(p Paragraph) {
+	p = displayed(p)
+	if *jsonOutput {
+		data, err := json.Marshal(p)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error encoding paragraph %d: %s\n", p.Number, err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+		return
+	}
+	if printedParagraphs > 0 && !*noSeparator {
+		fmt.Println(*separator)
+	}
+	printedParagraphs++
+	fmt.Println(hyperlinked(wrapped(p.String())))
+}
+
+// Matches a paragraph number like "484" or an inclusive range like "484-489"
+var reParagraphSpec = regexp.MustCompile(`^(\d+)(?:-(\d+))?$`)
+
+// isParagraphSpec reports whether arg names paragraphs, see parseParagraphSpec
+func isParagraphSpec(arg string) bool {
+	_, cited := citedParagraphs(arg)
+	return reParagraphSpec.MatchString(arg) || cited
+}
+
+// parseParagraphSpec returns the paragraph numbers named by a number or a range,
+// or by a citation like "CCC 484" or "§484-486"
+func parseParagraphSpec(spec string) ([]int, error) {
+	if !reParagraphSpec.MatchString(spec) {
+		cited, ok := citedParagraphs(spec)
+		if !ok {
+			return nil, fmt.Errorf("invalid paragraph number %q", spec)
+		}
+		var nums []int
+		for _, c := range cited {
+			specNums, err := parseRange(c, spec)
+			if err != nil {
+				return nil, err
+			}
+			nums = append(nums, specNums...)
+		}
+		return nums, nil
+	}
+	return parseRange(spec, spec)
+}
+
+// parseRange returns the numbers in a range like "484-489", or a single number,
+// naming spec, the argument it came from, in errors
+func parseRange(r, spec string) ([]int, error) {
+	matches := reParagraphSpec.FindStringSubmatch(r)
+	if matches == nil {
+		return nil, fmt.Errorf("invalid paragraph number %q", spec)
+	}
+	start, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid paragraph number %q", spec)
+	}
+	end := start
+	if matches[2] != "" {
+		end, err = strconv.Atoi(matches[2])
+		if err != nil || end < start {
+			return nil, fmt.Errorf("invalid paragraph range %q", spec)
+		}
+	}
+	// Checked before the numbers are listed, which for a huge range would take all the memory there is
+	if last := lastParagraph("en"); start < 1 || end > last {
+		return nil, fmt.Errorf("%q isn't within the paragraphs 1 to %d", spec, last)
+	}
+	nums := make([]int, 0, end-start+1)
+	for num := start; num <= end; num++ {
+		nums = append(nums, num)
+	}
+	return nums, nil
+}
+
+// paragraphsFromArgs returns the paragraphs named by numbers or ranges in args,
+// exiting if any of them are invalid or can't be found
+func paragraphsFromArgs(paragraphs map[int]Paragraph, args []string) []Paragraph {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "expected a paragraph number, like 484")
+		exit(2)
+	}
+	var ps []Paragraph
+	for _, arg := range args {
+		nums, err := parseParagraphSpec(arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			exit(2)
+		}
+		for _, num := range nums {
+			p, found := paragraphs[num]
+			if !found {
+				fmt.Fprintf(os.Stderr, "paragraph %d not found\n", num)
+				exit(1)
+			}
+			ps = append(ps, p)
+		}
+	}
+	return ps
+}
+
+// printNumbers prints the paragraphs with the given numbers in order. Unknown
+// paragraphs are reported on stderr and skipped, and printNumbers returns false
+// if there were any.
+func printNumbers(nums []int, paragraphs map[int]Paragraph) bool {
+	ok := true
+	for _, num := range nums {
+		p, found := paragraphs[num]
+		if !found {
+			fmt.Fprintf(os.Stderr, "paragraph %d not found\n", num)
+			ok = false
+			continue
+		}
+		if *briefOnly && !p.InBrief {
+			fmt.Fprintf(os.Stderr, "%d is not an \"IN BRIEF\" paragraph\n", num)
+			ok = false
+			continue
+		}
+		printParagraph(p)
+	}
+	return ok
+}
+
+// printSpec prints the paragraphs named by a number or range, or a point of a
+// paragraph named like "484.2". Bad or unknown numbers are reported on stderr,
+// and printSpec returns false if there were any.
+func printSpec(spec string, paragraphs map[int]Paragraph) bool {
+	if num, point, ok := parsePointSpec(spec); ok {
+		p, found := paragraphs[num]
+		if !found {
+			fmt.Fprintf(os.Stderr, "paragraph %d not found\n", num)
+			return false
+		}
+		return printPoint(p, point)
+	}
+	nums, err := parseParagraphSpec(spec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return false
+	}
+	return printNumbers(nums, paragraphs)
+}
+
+// printFromReader prints every paragraph whose number (or range, or point)
+// appears in r, separated by any whitespace. Bad or unknown numbers are reported
+// on stderr and skipped, and printFromReader returns false if there were any.
+func printFromReader(r io.Reader, paragraphs map[int]Paragraph) bool {
+	ok := true
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanWords)
+	for scanner.Scan() {
+		if !printSpec(scanner.Text(), paragraphs) {
+			ok = false
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "error reading stdin: %s\n", err)
+		return false
+	}
+	return ok
+}
+
+// sortedNumbers returns the paragraph numbers in ascending order
+func sortedNumbers(paragraphs map[int]Paragraph) []int {
+	nums := make([]int, 0, len(paragraphs))
+	for num := range paragraphs {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	return nums
+}
+
+// compactText strips the leading paragraph number from text and puts it all on one line,
+// so it can be used as a single tab separated field
+func compactText(text string) string {
+	text = reLeadingNumber.ReplaceAllString(strings.TrimSpace(text), "")
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// The table of contents, which some navigation links point back to
+var vaticanIndexPage, _ = vaticanURL("/_INDEX.HTM")
+
+// getNextLink returns the URL of the page after doc, or "" if doc is the last page.
+// A "Next" link to the index or to a page that was already visited doesn't count,
+// since the navigation on some pages wraps around instead of ending.
+func getNextLink(doc *goquery.Document, visited map[string]bool) string {
+	return nextLink(doc, visited, vaticanURL, vaticanIndexPage)
+}
+
+// nextLink is getNextLink for any archive laid out like the Vatican's, whose
+// links are resolved with resolve and whose table of contents is index
+func nextLink(doc *goquery.Document, visited map[string]bool, resolve func(string) (string, error), index string) string {
+	var next string
+	doc.Find("a").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		if s.Text() != "Next" {
+			return true
+		}
+		href, _ := s.Attr("href")
+		urlStr, err := resolve(href)
+		if err != nil {
+			fmt.Printf("error resolving the next link %s\n", href)
+			return true
+		}
+		// The fragment only says where on the page to scroll to, it's the same page
+		urlStr, _, _ = strings.Cut(urlStr, "#")
+		if strings.EqualFold(urlStr, index) || visited[urlStr] {
+			return true
+		}
+		next = urlStr
+		return false
 	})
 	return next
 }
 
+// Matches a leading paragraph number like "484" or a range like "484-489"
+var reLeadingNumber = regexp.MustCompile(`^(\d+)(?:\s*[-–]\s*(\d+))?`)
+
 func extractNumber(str string) (int, bool) {
-	re := regexp.MustCompile(`^(\d+)`)
-	matches := re.FindStringSubmatch(str)
+	num, _, ok := extractRange(str)
+	return num, ok
+}
+
+// extractRange is like extractNumber, but also recognizes a leading range such as
+// "484-489." which heads grouped or summary paragraphs. For a single number the
+// end of the range is the same as the start.
+func extractRange(str string) (int, int, bool) {
+	matches := reLeadingNumber.FindStringSubmatch(str)
 	if len(matches) > 1 {
-		num, err := strconv.Atoi(matches[1])
-		if err != nil {
-			return 0, false
+		start, ok := paragraphNumber(matches[1])
+		if !ok {
+			return 0, 0, false
+		}
+		if matches[2] == "" {
+			return start, start, true
 		}
-		return num, true
+		end, ok := paragraphNumber(matches[2])
+		if !ok || end < start {
+			// not a sensible range, so just treat it as a single number
+			return start, start, true
+		}
+		return start, end, true
 	}
-	return 0, false
+	return 0, 0, false
 }
 
-func vaticanURL(relativePath string) (string, error) {
-	// Forgive these web developers, some next links are absolute and some are relative
-	if strings.HasPrefix(strings.ToLower(relativePath), "http") {
-		return relativePath, nil
+// paragraphNumber parses digits as a paragraph number. Numbers that no paragraph
+// could have, like "007" or "0", are rejected so that they aren't mistaken for
+// paragraphs. A number past the last paragraph is kept, for findGaps to report.
+func paragraphNumber(digits string) (int, bool) {
+	if strings.HasPrefix(digits, "0") {
+		return 0, false
 	}
-	u, err := url.Parse(vatican)
+	num, err := strconv.Atoi(digits)
+	if err != nil || num < 1 {
+		return 0, false
+	}
+	return num, true
+}
+
+// vaticanURL resolves a link found on one of the archive's pages. The result is
+// always an absolute URL: the link itself if it was an absolute http(s) one,
+// otherwise a page on the Vatican's site.
+func vaticanURL(relativePath string) (string, error) {
+	// The trailing slash makes relative links resolve inside the archive directory
+	base, err := url.Parse(vatican + archeng + "/")
 	if err != nil {
 		return "", err
 	}
-
-	rel, err := url.Parse(path.Join(archeng, relativePath))
+	// Resolve the link as a URL rather than joining paths, so that a query
+	// string or fragment (like "__P3.HTM#top") survives intact
+	rel, err := url.Parse(relativePath)
 	if err != nil {
 		return "", err
 	}
+	// Forgive these web developers, some next links are absolute and some are relative
+	if scheme := strings.ToLower(rel.Scheme); (scheme == "http" || scheme == "https") && rel.Host != "" {
+		return relativePath, nil
+	}
+	if rel.Scheme != "" || rel.Host != "" {
+		// Like "mailto:" or "javascript:", or "//example.com/" without a scheme
+		return "", fmt.Errorf("%q isn't a page in the archive", relativePath)
+	}
+	// Paths like "/__P2.HTM" are relative to the archive, not to the site root
+	if strings.HasPrefix(rel.Path, "/") && !strings.HasPrefix(rel.Path, archeng+"/") {
+		rel.Path = strings.TrimPrefix(rel.Path, "/")
+	}
 
-	resolvedURL := u.ResolveReference(rel)
+	resolvedURL := base.ResolveReference(rel)
 	return resolvedURL.String(), nil
 }
 
-
 func createPositionFile() {
 	filename := "/tmp/.ccc_pos"
 
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestGetNextLinkLastPage(t *testing.T) {
+	// On the last page, "Next" goes back to the table of contents
+	doc := readFixture(t, "last_page.html")
+	if next := getNextLink(doc, map[string]bool{}); next != "" {
+		t.Errorf("got %q after the last page, want none", next)
+	}
+}
+
+func TestGetNextLinkVisited(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<p><a href="__P2.HTM">Next</a></p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := vaticanURL("__P2.HTM")
+	if next := getNextLink(doc, nil); next != page {
+		t.Errorf("got %q, want %q", next, page)
+	}
+	// A "Next" link that wraps around to a page already crawled ends the crawl
+	if next := getNextLink(doc, map[string]bool{page: true}); next != "" {
+		t.Errorf("got %q for a visited page, want none", next)
+	}
+}
+
+func TestVaticanURL(t *testing.T) {
+	tests := []struct {
+		href, want string
+	}{
+		{"__P3.HTM", "https://www.vatican.va/archive/ENG0015/__P3.HTM"},
+		// The fragment and query are kept as they are, not joined into the path
+		{"__P3.HTM#top", "https://www.vatican.va/archive/ENG0015/__P3.HTM#top"},
+		{"page?x=1", "https://www.vatican.va/archive/ENG0015/page?x=1"},
+		{"page?x=1#top", "https://www.vatican.va/archive/ENG0015/page?x=1#top"},
+		{"/archive/ENG0015/__P3.HTM", "https://www.vatican.va/archive/ENG0015/__P3.HTM"},
+		{"https://www.vatican.va/archive/ENG0015/__P4.HTM", "https://www.vatican.va/archive/ENG0015/__P4.HTM"},
+	}
+	for _, tt := range tests {
+		got, err := vaticanURL(tt.href)
+		if err != nil {
+			t.Errorf("vaticanURL(%q): %s", tt.href, err)
+		} else if got != tt.want {
+			t.Errorf("vaticanURL(%q) = %q, want %q", tt.href, got, tt.want)
+		}
+	}
+}
+
+func TestURLToFilename(t *testing.T) {
+	tests := []struct {
+		url, want string
+	}{
+		{"https://www.vatican.va/archive/ENG0015/__P3.HTM", "_archive_ENG0015___P3.HTM"},
+		// The fragment is where to scroll to on the same page
+		{"https://www.vatican.va/archive/ENG0015/__P3.HTM#top", "_archive_ENG0015___P3.HTM"},
+		// Only the path names the file, see hashedFilename for telling queries apart
+		{"https://www.vatican.va/archive/ENG0015/page?x=1", "_archive_ENG0015_page"},
+		// An empty path would name the cache directory itself
+		{"https://www.vatican.va/", "_."},
+		// Another host's pages are named after it too, so they don't share names with the Vatican's
+		{"https://example.org/archive/ENG0015/__P3.HTM", "_example.org_archive_ENG0015___P3.HTM"},
+		{"https://Example.ORG:8080/", "_example.org8080"},
+		{"https://WWW.VATICAN.VA/archive/ENG0015/__P3.HTM", "_archive_ENG0015___P3.HTM"},
+	}
+	for _, tt := range tests {
+		if got, err := urlToFilename(tt.url); err != nil {
+			t.Errorf("urlToFilename(%q): %s", tt.url, err)
+		} else if got != tt.want {
+			t.Errorf("urlToFilename(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+	if got, err := urlToFilename("https://www.vatican.va/%zz"); err == nil {
+		t.Errorf("urlToFilename of a malformed url = %q, want an error", got)
+	}
+}
+
+func TestParseParagraphSpec(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []int // nil for an invalid spec
+	}{
+		{"484", []int{484}},
+		{"484-486", []int{484, 485, 486}},
+		{"484-484", []int{484}},
+		{"1", []int{1}},
+		{"2865", []int{2865}},
+		{"CCC 484", []int{484}},
+		{"§484-486", []int{484, 485, 486}},
+		{"cf. 484, 486", []int{484, 486}},
+		{"0", nil},
+		{"0-3", nil},
+		{"-5", nil},
+		{"-5-3", nil},
+		{"486-484", nil},
+		{"2866", nil},
+		{"1-2866", nil},
+		{"1-99999999999999999999", nil},
+		{"99999999999999999999", nil},
+		{"abc", nil},
+		{"484a", nil},
+		{"484-", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		got, err := parseParagraphSpec(tt.spec)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("parseParagraphSpec(%q) = %v, want an error", tt.spec, got)
+			}
+		} else if err != nil {
+			t.Errorf("parseParagraphSpec(%q): %s", tt.spec, err)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseParagraphSpec(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParagraphNumber(t *testing.T) {
+	tests := []struct {
+		digits string
+		want   int // 0 if it isn't a paragraph number
+	}{
+		{"1", 1},
+		{"484", 484},
+		{"2865", 2865},
+		// Past the last paragraph, for findGaps to report
+		{"2866", 2866},
+		{"3000", 3000},
+		{"0", 0},
+		{"007", 0},
+		{"99999999999999999999", 0},
+	}
+	for _, tt := range tests {
+		num, ok := paragraphNumber(tt.digits)
+		if ok != (tt.want != 0) || num != tt.want {
+			t.Errorf("paragraphNumber(%q) = %d, %v, want %d", tt.digits, num, ok, tt.want)
+		}
+	}
+}
+
+func FuzzExtractNumber(f *testing.F) {
+	for _, seed := range []string{
+		"484 The Annunciation to Mary inaugurates \"the fullness of time,\"",
+		"2865 By the final \"Amen,\" we express our \"fiat\"",
+		"1 God, infinitely perfect and blessed in himself",
+		"487-489. IN BRIEF",
+		"508 – 511",
+		"489-487",
+		"007",
+		"0",
+		"2866 past the end",
+		"1992",
+		"ARTICLE 3",
+		"",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, str string) {
+		num, end, ok := extractRange(str)
+		if !ok {
+			if num != 0 || end != 0 {
+				t.Errorf("extractRange(%q) = %d, %d but not ok", str, num, end)
+			}
+			return
+		}
+		if num < 1 || end < num {
+			t.Errorf("extractRange(%q) = %d, %d, which isn't a range of paragraphs", str, num, end)
+		}
+		if !strings.HasPrefix(str, strconv.Itoa(num)) {
+			t.Errorf("extractRange(%q) = %d, which isn't the start of the text", str, num)
+		}
+		if got, _ := extractNumber(str); got != num {
+			t.Errorf("extractNumber(%q) = %d, but extractRange starts at %d", str, got, num)
+		}
+	})
+}
+
+func FuzzURLToFilename(f *testing.F) {
+	for _, seed := range []string{
+		"https://www.vatican.va/archive/ENG0015/__P3.HTM",
+		"https://www.vatican.va/archive/ENG0015/__P3.HTM#top",
+		"https://www.vatican.va/archive/ENG0015/_INDEX.HTM",
+		"https://www.vatican.va/archive/ENG0015/page?x=1",
+		"https://www.vatican.va/",
+		"https://www.vatican.va/..",
+		"https://www.vatican.va/%2e%2e",
+		"https://www.vatican.va/a\\..\\..\\b",
+		"https://www.vatican.va/%zz",
+		"https://example.org/..",
+		"https://../..",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, urlStr string) {
+		name, err := urlToFilename(urlStr)
+		if err != nil {
+			return
+		}
+		// The name has to stay inside the cache directory
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+			t.Errorf("urlToFilename(%q) = %q, which isn't a single file name", urlStr, name)
+		}
+	})
+}
+
+func FuzzVaticanURL(f *testing.F) {
+	for _, seed := range []string{
+		"__P3.HTM",
+		"__P3.HTM#top",
+		"_INDEX.HTM#top",
+		"page?x=1",
+		"/__P2.HTM",
+		"/archive/ENG0015/__P3.HTM",
+		"https://www.vatican.va/archive/ENG0015/__P4.HTM",
+		"HTTP://WWW.VATICAN.VA/archive/ENG0015/__P4.HTM",
+		"mailto:someone@example.org",
+		"javascript:void(0)",
+		"//example.com/",
+		"%zz",
+		"",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, href string) {
+		urlStr, err := vaticanURL(href)
+		if err != nil {
+			return
+		}
+		// Whatever the link was, the result is an absolute web page
+		u, err := url.Parse(urlStr)
+		if err != nil {
+			t.Fatalf("vaticanURL(%q) = %q, which can't be parsed: %s", href, urlStr, err)
+		}
+		if scheme := strings.ToLower(u.Scheme); (scheme != "http" && scheme != "https") || u.Host == "" {
+			t.Errorf("vaticanURL(%q) = %q, which isn't an absolute http(s) URL", href, urlStr)
+		}
+	})
+}
+
+func TestFindGapsExtra(t *testing.T) {
+	// A paragraph past the last one is parsed, so that it's reported
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader("<p>1 First.</p><p>2 Second.</p><p>3000 Too far.</p>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	paragraphs := make(map[int]Paragraph)
+	for _, p := range parsePage(doc, "", &parseState{}) {
+		paragraphs[p.Number] = p
+	}
+	missing, extra := findGaps(paragraphs, 3)
+	if !reflect.DeepEqual(missing, []int{3}) || !reflect.DeepEqual(extra, []int{3000}) {
+		t.Errorf("findGaps = %v missing and %v extra, want [3] and [3000]", missing, extra)
+	}
+}
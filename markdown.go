@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var splitDir = flag.String("split-dir", "", "with export, write every paragraph to its own Markdown file in `dir`, like dir/0484.md")
+
+// markdownFilename returns the name of the file a paragraph is split into. The
+// numbers are zero padded to four digits so the files sort in order.
+func markdownFilename(num int) string {
+	return fmt.Sprintf("%04d.md", num)
+}
+
+// ExportSplit writes every paragraph to its own Markdown file in dir, creating
+// dir if it doesn't exist. Files left by an earlier export are overwritten,
+// with a warning first.
+func (c *Catechism) ExportSplit(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	nums := sortedNumbers(c.Paragraphs)
+	existing := 0
+	for _, num := range nums {
+		if _, err := os.Stat(filepath.Join(dir, markdownFilename(num))); err == nil {
+			existing++
+		}
+	}
+	if existing > 0 {
+		fmt.Fprintf(os.Stderr, "warning: overwriting %d existing files in %s\n", existing, dir)
+	}
+	for _, num := range nums {
+		if err := writeMarkdownFile(filepath.Join(dir, markdownFilename(num)), c.Paragraphs[num], c.Paragraphs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeMarkdownFile(name string, p Paragraph, paragraphs map[int]Paragraph) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	err = writeMarkdown(file, p, paragraphs)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// writeMarkdown writes p as Markdown, with YAML front matter holding its number,
+// the paragraphs it references and where it is in the tree:
+//
+//	---
+//	number: 484
+//	references: ["485"]
+//	path: ["PART ONE ...", "SECTION TWO ...", ...]
+//	---
+//
+//	The Annunciation to Mary inaugurates ...
+//
+// With --link-refs, the paragraph references in the text link to the files of
+// the paragraphs they cite, like [485](0485.md), if they're in paragraphs.
+func writeMarkdown(w io.Writer, p Paragraph, paragraphs map[int]Paragraph) error {
+	p = displayed(p)
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "---")
+	fmt.Fprintf(bw, "number: %d\n", p.Number)
+	fmt.Fprintf(bw, "references: %s\n", yamlList(p.References))
+	fmt.Fprintf(bw, "path: %s\n", yamlList(p.Location()))
+	fmt.Fprintln(bw, "---")
+	fmt.Fprintln(bw)
+	fmt.Fprintln(bw, markdownText(compactText(p.Text), paragraphs))
+	if len(p.Points) > 0 {
+		fmt.Fprintln(bw)
+		for _, point := range p.Points {
+			fmt.Fprintf(bw, "- %s\n", markdownText(strings.Join(strings.Fields(point), " "), paragraphs))
+		}
+	}
+	return bw.Flush()
+}
+
+// markdownText returns text, already on one line, with --link-refs linking its paragraph references
+func markdownText(text string, paragraphs map[int]Paragraph) string {
+	if !*linkRefs {
+		return text
+	}
+	var b strings.Builder
+	for _, span := range referenceSpans(text) {
+		if num, ok := linkTarget(span.Nums, paragraphs); ok {
+			fmt.Fprintf(&b, "[%s](%s)", span.Text, markdownFilename(num))
+		} else {
+			b.WriteString(span.Text)
+		}
+	}
+	return b.String()
+}
+
+// yamlList formats values as a YAML flow sequence of double quoted strings,
+// which use the same escapes as Go's quoted strings
+func yamlList(values []string) string {
+	list := "["
+	for i, value := range values {
+		if i > 0 {
+			list += ", "
+		}
+		list += strconv.Quote(value)
+	}
+	return list + "]"
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Counters for `ccc serve`'s /metrics. They're updated with sync/atomic, since
+// the server handles requests concurrently.
+var (
+	cacheHits   int64 // pages getOnce found in the cache
+	cacheMisses int64 // pages getOnce had to fetch
+	crawls      int64 // crawls started by serve, the first one and every reload
+	// How long the last crawl took, in nanoseconds
+	lastCrawlDuration int64
+
+	requestsMu sync.Mutex
+	requests   = make(map[string]int64) // requests served, by endpoint
+)
+
+// countRequest counts one request to endpoint
+func countRequest(endpoint string) {
+	requestsMu.Lock()
+	defer requestsMu.Unlock()
+	requests[endpoint]++
+}
+
+// recordCrawl counts a crawl that took d
+func recordCrawl(d time.Duration) {
+	atomic.AddInt64(&crawls, 1)
+	atomic.StoreInt64(&lastCrawlDuration, int64(d))
+}
+
+// writeMetrics writes the metrics in the Prometheus text exposition format
+func writeMetrics(w io.Writer, paragraphs int) {
+	fmt.Fprintln(w, "# HELP ccc_requests_total Requests served, by endpoint.")
+	fmt.Fprintln(w, "# TYPE ccc_requests_total counter")
+	requestsMu.Lock()
+	endpoints := make([]string, 0, len(requests))
+	for endpoint := range requests {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	for _, endpoint := range endpoints {
+		fmt.Fprintf(w, "ccc_requests_total{endpoint=%q} %d\n", endpoint, requests[endpoint])
+	}
+	requestsMu.Unlock()
+
+	fmt.Fprintln(w, "# HELP ccc_cache_hits_total Pages read from the cache.")
+	fmt.Fprintln(w, "# TYPE ccc_cache_hits_total counter")
+	fmt.Fprintf(w, "ccc_cache_hits_total %d\n", atomic.LoadInt64(&cacheHits))
+	fmt.Fprintln(w, "# HELP ccc_cache_misses_total Pages fetched because they weren't in the cache.")
+	fmt.Fprintln(w, "# TYPE ccc_cache_misses_total counter")
+	fmt.Fprintf(w, "ccc_cache_misses_total %d\n", atomic.LoadInt64(&cacheMisses))
+	fmt.Fprintln(w, "# HELP ccc_crawls_total Crawls of the catechism, including reloads.")
+	fmt.Fprintln(w, "# TYPE ccc_crawls_total counter")
+	fmt.Fprintf(w, "ccc_crawls_total %d\n", atomic.LoadInt64(&crawls))
+	fmt.Fprintln(w, "# HELP ccc_last_crawl_duration_seconds How long the last crawl took.")
+	fmt.Fprintln(w, "# TYPE ccc_last_crawl_duration_seconds gauge")
+	fmt.Fprintf(w, "ccc_last_crawl_duration_seconds %g\n", time.Duration(atomic.LoadInt64(&lastCrawlDuration)).Seconds())
+	fmt.Fprintln(w, "# HELP ccc_paragraphs Paragraphs in the catechism being served.")
+	fmt.Fprintln(w, "# TYPE ccc_paragraphs gauge")
+	fmt.Fprintf(w, "ccc_paragraphs %d\n", paragraphs)
+}
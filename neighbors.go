@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// How much of each paragraph neighbors shows
+const snippetLength = 60
+
+// neighbors prints the paragraphs each paragraph in args references, and the
+// paragraphs that reference it
+func neighbors(c *Catechism, args []string) {
+	paragraphs := c.Paragraphs
+	for i, p := range paragraphsFromArgs(paragraphs, args) {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%d references:\n", p.Number)
+		var outbound []int
+		for _, ref := range p.References {
+			outbound = append(outbound, referencedNumbers(ref)...)
+		}
+		sort.Ints(outbound)
+		printNeighbors(outbound, paragraphs)
+		fmt.Printf("%d is referenced by:\n", p.Number)
+		printNeighbors(c.InboundRefs(p.Number), paragraphs)
+	}
+}
+
+// InboundRefs returns the paragraphs that reference paragraph n, in order. The
+// reverse index is built the first time it's needed and shared after that, so
+// the returned slice must not be changed.
+func (c *Catechism) InboundRefs(n int) []int {
+	c.inboundOnce.Do(func() {
+		c.inbound = make(map[int][]int)
+		for _, num := range sortedNumbers(c.Paragraphs) {
+			seen := make(map[int]bool)
+			for _, ref := range c.Paragraphs[num].References {
+				for _, to := range referencedNumbers(ref) {
+					if !seen[to] {
+						seen[to] = true
+						c.inbound[to] = append(c.inbound[to], num)
+					}
+				}
+			}
+		}
+	})
+	return c.inbound[n]
+}
+
+// printNeighbors prints one indented line per paragraph in nums, with the start of its text
+func printNeighbors(nums []int, paragraphs map[int]Paragraph) {
+	if len(nums) == 0 {
+		fmt.Println("  (none)")
+		return
+	}
+	for _, num := range nums {
+		p, found := paragraphs[num]
+		if !found {
+			fmt.Printf("  %d  (not in the crawl)\n", num)
+			continue
+		}
+		fmt.Printf("  %d  %s\n", num, snippet(compactText(displayed(p).Text)))
+	}
+}
+
+// snippet shortens text to about snippetLength characters, breaking between words
+func snippet(text string) string {
+	runes := []rune(text)
+	if len(runes) <= snippetLength {
+		return text
+	}
+	cut := string(runes[:snippetLength])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return cut + "..."
+}
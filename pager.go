@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+var (
+	pager   = flag.Bool("pager", true, "page output through $PAGER (or less) when stdout is a terminal")
+	noPager = flag.Bool("no-pager", false, "never page output, the same as --pager=false")
+)
+
+// stopPaging is set by startPager, see exit
+var stopPaging = func() {}
+
+// exit is os.Exit for code that may run while output is paged. It waits for the
+// pager first, since exiting while it runs would leave it fighting the shell over the terminal.
+func exit(code int) {
+	stopPaging()
+	os.Exit(code)
+}
+
+// startPager sends everything printed to stdout through $PAGER, like git does,
+// and returns a function that waits for the pager to be closed. Output is only
+// paged when stdout is a terminal and isn't going to --output. By default less
+// is run with -FRX, so output that fits on the screen is just printed.
+func startPager() (stop func()) {
+	stop = func() {}
+	if !*pager || *noPager || *outputFile != "" || !isTerminal(os.Stdout) {
+		return stop
+	}
+	command, ok := os.LookupEnv("PAGER")
+	if !ok {
+		command = "less"
+	}
+	if command == "" || command == "cat" {
+		return stop
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return stop
+	}
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = r
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		cmd.Env = append(cmd.Env, "LESS=FRX")
+	}
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "error starting pager %q: %s\n", command, err)
+		r.Close()
+		w.Close()
+		return stop
+	}
+	r.Close()
+	stdout := os.Stdout
+	os.Stdout = w
+	var once sync.Once
+	stopPaging = func() {
+		once.Do(func() {
+			os.Stdout = stdout
+			// Closing the pipe tells the pager there's no more output
+			w.Close()
+			cmd.Wait()
+		})
+	}
+	return stopPaging
+}
+
+// isTerminal returns whether f is a terminal rather than a file or a pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
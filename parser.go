@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// A Parser extracts the numbered paragraphs from the pages of one source of the
+// catechism. The crawl fetches the pages, follows the links between them and
+// collects the paragraphs, and calls ParsePage on each page in the order they
+// come in, so a Parser can keep track of where it is, like the part or article
+// the last page ended in. A Parser for another source, like a different site or
+// the text of a scanned print edition, can be crawled the same way.
+//
+// Paragraphs without a SourceURL are given the URL of the page they're on.
+type Parser interface {
+	ParsePage(doc *goquery.Document) ([]Paragraph, error)
+}
+
+// Errors a Parser returns for a page that it couldn't find anything on. The
+// crawl warns about each one and carries on with the next page.
+var (
+	// The page has none of the elements paragraphs are in, which for a page
+	// that looks fine means the source's markup has changed
+	errNoParagraphElements = errors.New("there are no paragraph elements on the page")
+	// The page has neither paragraphs nor headings, like an error page
+	errNothingFound = errors.New("there is nothing on the page")
+)
+
+// A VaticanParser parses the pages of the Vatican's archive of the catechism,
+// finding paragraphs and headings in the elements matching --selector
+type VaticanParser struct {
+	state parseState
+}
+
+// NewVaticanParser returns a VaticanParser for the pages of the catechism from the first one
+func NewVaticanParser() *VaticanParser {
+	return &VaticanParser{}
+}
+
+// ParsePage returns the paragraphs on doc
+func (vp *VaticanParser) ParsePage(doc *goquery.Document) ([]Paragraph, error) {
+	if doc.Find(*paragraphSelector).Length() == 0 {
+		return nil, errNoParagraphElements
+	}
+	before := vp.state.loc
+	found := parsePage(doc, "", &vp.state)
+	if len(found) == 0 && vp.state.loc == before {
+		return nil, errNothingFound
+	}
+	return found, nil
+}
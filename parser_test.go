@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// parseFixture parses the page in testdata/name with a new VaticanParser
+func parseFixture(t *testing.T, name string) []Paragraph {
+	t.Helper()
+	found, err := NewVaticanParser().ParsePage(readFixture(t, name))
+	if err != nil {
+		t.Fatalf("parsing %s: %s", name, err)
+	}
+	return found
+}
+
+// readFixture reads the page in testdata/name
+func readFixture(t *testing.T, name string) *goquery.Document {
+	t.Helper()
+	doc, _, err := readLocalPage(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestParseInBrief(t *testing.T) {
+	found := parseFixture(t, "in_brief.html")
+	want := []struct {
+		num     int
+		inBrief bool
+	}{
+		{484, false},
+		{485, false},
+		// The range "487-489." heads a group, it isn't paragraph 487
+		{508, true},
+		{509, true},
+		// A new article ends the IN BRIEF section
+		{571, false},
+	}
+	if len(found) != len(want) {
+		t.Fatalf("found %d paragraphs, want %d: %v", len(found), len(want), found)
+	}
+	for i, w := range want {
+		if found[i].Number != w.num || found[i].InBrief != w.inBrief {
+			t.Errorf("paragraph %d: got %d with InBrief %v, want %d with InBrief %v", i, found[i].Number, found[i].InBrief, w.num, w.inBrief)
+		}
+	}
+}
+
+func TestParseWindows1252(t *testing.T) {
+	// The page is in Windows-1252, as its <meta> tag says, so the accents and
+	// curly quotes are single bytes that aren't valid UTF-8
+	found := parseFixture(t, "windows1252.html")
+	if len(found) != 2 || found[1].Number != 28 {
+		t.Fatalf("found %v, want paragraphs 27 and 28", found)
+	}
+	for _, want := range []string{"“beatific vision”", "naïve", "Créateur, Père"} {
+		if !strings.Contains(found[1].Text, want) {
+			t.Errorf("paragraph 28 doesn't contain %q: %q", want, found[1].Text)
+		}
+	}
+}
+
+func TestReadPageWindows1252(t *testing.T) {
+	// A cached response without the <meta> tag is decoded by its Content-Type
+	page, err := os.ReadFile(filepath.Join("testdata", "windows1252.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	page = regexp.MustCompile(`<meta[^>]*>`).ReplaceAll(page, nil)
+	dumped := fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: text/html; charset=windows-1252\r\nContent-Length: %d\r\n\r\n%s", len(page), page)
+	doc, status, size, err := readPage(strings.NewReader(dumped))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != 200 || size != int64(len(page)) {
+		t.Errorf("got status %d and size %d, want 200 and %d", status, size, len(page))
+	}
+	if text := doc.Find("p").Eq(2).Text(); !strings.Contains(text, "Créateur, Père") {
+		t.Errorf("paragraph 28 wasn't decoded: %q", text)
+	}
+}
+
+func TestParseListPoints(t *testing.T) {
+	found := parseFixture(t, "list.html")
+	if len(found) != 2 {
+		t.Fatalf("found %v, want paragraphs 2052 and 2053", found)
+	}
+	// The lists after 2052 are its points, on one line each, without the empty item
+	want := []string{"You shall not kill,", "You shall not commit adultery,", "You shall not steal,", "Honor your father and mother."}
+	if !reflect.DeepEqual(found[0].Points, want) {
+		t.Errorf("paragraph 2052 has the points %q, want %q", found[0].Points, want)
+	}
+	if strings.Contains(found[0].Text, "kill") {
+		t.Errorf("paragraph 2052's text has its points in it: %q", found[0].Text)
+	}
+	if found[1].Points != nil {
+		t.Errorf("paragraph 2053 has the points %q, want none", found[1].Points)
+	}
+}
+
+func TestParagraphOnPageNormalized(t *testing.T) {
+	// "é" as an "e" followed by a combining acute accent, as some pages have it
+	decomposed := "Cre\u0301ateur"
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader("<p>27 Dieu " + decomposed + " (cf. 28)</p><p>28 Le " + decomposed + "</p>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, found := paragraphOnPage(doc, "https://www.vatican.va/archive/ENG0015/__P4.HTM", 0, 27)
+	if !found {
+		t.Fatal("paragraph 27 wasn't found")
+	}
+	// Looked up the same as crawled, with the accent composed into one "é"
+	if want := "27 Dieu Créateur (cf. 28)"; p.Text != want {
+		t.Errorf("got the text %q, want %q", p.Text, want)
+	}
+	if p.SourceURL != "https://www.vatican.va/archive/ENG0015/__P4.HTM" {
+		t.Errorf("got the source %q, want the page it was on", p.SourceURL)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Matches one of the numbered points of a paragraph, like "484.2"
+var rePointSpec = regexp.MustCompile(`^(\d+)\.(\d+)$`)
+
+// Matches the marker of a numbered point in the text of a paragraph, like "1°",
+// "2º" or "(3)", at the start of the text or after a space or punctuation
+var rePointMarker = regexp.MustCompile(`(?:^|[\s:;,.])(?:(\d{1,2})\s?[°º]|\((\d{1,2})\))\s`)
+
+// parsePointSpec parses a spec like "484.2" as paragraph 484, point 2
+func parsePointSpec(spec string) (num, point int, ok bool) {
+	matches := rePointSpec.FindStringSubmatch(spec)
+	if matches == nil {
+		return 0, 0, false
+	}
+	num, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return 0, 0, false
+	}
+	point, err = strconv.Atoi(matches[2])
+	if err != nil || point < 1 {
+		return 0, 0, false
+	}
+	return num, point, true
+}
+
+// points returns the numbered points of p: the items of its lists if it has any,
+// or else the points marked in its text like "1° ... 2° ...", see inlinePoints
+func (p Paragraph) points() []string {
+	if len(p.Points) > 0 {
+		return p.Points
+	}
+	return inlinePoints(compactText(p.Text))
+}
+
+// inlinePoints splits the points marked in text like "1° ... 2° ..." into the
+// text of each one. Only markers numbered 1, 2, 3 and so on in order count, so
+// that a stray "(4)" in the middle of a sentence isn't taken for a point, and
+// there have to be at least two. The text before the first point isn't one.
+func inlinePoints(text string) []string {
+	var starts, ends []int
+	expected := 1
+	for _, m := range rePointMarker.FindAllStringSubmatchIndex(text, -1) {
+		var digits string
+		if m[2] >= 0 {
+			digits = text[m[2]:m[3]]
+		} else {
+			digits = text[m[4]:m[5]]
+		}
+		if n, err := strconv.Atoi(digits); err != nil || n != expected {
+			continue
+		}
+		starts = append(starts, m[0])
+		ends = append(ends, m[1])
+		expected++
+	}
+	if len(starts) < 2 {
+		return nil
+	}
+	points := make([]string, len(starts))
+	for i := range starts {
+		end := len(text)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		// The separator before the next point isn't part of this one
+		points[i] = strings.TrimRight(strings.TrimSpace(text[ends[i]:end]), ",;")
+	}
+	return points
+}
+
+// printPoint prints point of paragraph p as "484.2  <text>", or with --json as an
+// object. It returns false, after saying so on stderr, if p has no such point.
+func printPoint(p Paragraph, point int) bool {
+	points := p.points()
+	if point > len(points) {
+		if len(points) == 0 {
+			fmt.Fprintf(os.Stderr, "paragraph %d has no numbered points\n", p.Number)
+		} else {
+			fmt.Fprintf(os.Stderr, "paragraph %d has only %d points\n", p.Number, len(points))
+		}
+		return false
+	}
+	text := displayed(Paragraph{Text: points[point-1]}).Text
+	if *jsonOutput {
+		data, err := json.Marshal(struct {
+			Number int    `json:"number"`
+			Point  int    `json:"point"`
+			Text   string `json:"text"`
+		}{p.Number, point, text})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error encoding paragraph %d.%d: %s\n", p.Number, point, err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+		return true
+	}
+	if printedParagraphs > 0 && !*noSeparator {
+		fmt.Println(*separator)
+	}
+	printedParagraphs++
+	fmt.Println(hyperlinked(wrapped(fmt.Sprintf("%d.%d  %s", p.Number, point, text))))
+	return true
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var prefetchWorkers = flag.Int("prefetch", 0, "fetch up to `N` pages at once ahead of the crawl, from the page links on each page")
+
+// Links to the archive's pages, like "__P3.HTM" or "__PG1.HTM"
+var rePageLink = regexp.MustCompile(`(?i)/__P[0-9A-Z]+\.HTM$`)
+
+// The most pages that can wait in the prefetch queue, far more than the archive has
+const prefetchQueueSize = 4096
+
+// A prefetcher fetches pages into the cache ahead of the crawl. The crawl still
+// has to find each page's "Next" link before it knows which page comes next,
+// but the pages usually link to many of the pages after them, and those can be
+// fetched meanwhile. A nil prefetcher does nothing, so the crawl can use one either way.
+type prefetcher struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	queue  chan string
+
+	mu     sync.Mutex
+	queued map[string]chan struct{} // closed once the page has been fetched (or given up on)
+}
+
+// newPrefetcher starts workers that fetch queued pages, or returns nil if workers is 0
+func newPrefetcher(ctx context.Context, workers int) *prefetcher {
+	if workers <= 0 {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	p := &prefetcher{
+		ctx:    ctx,
+		cancel: cancel,
+		queue:  make(chan string, prefetchQueueSize),
+		queued: make(map[string]chan struct{}),
+	}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for urlStr := range p.queue {
+				if ctx.Err() == nil {
+					p.fetch(urlStr)
+				}
+				p.done(urlStr)
+			}
+		}()
+	}
+	return p
+}
+
+// add queues every page in pages that hasn't been queued already
+func (p *prefetcher) add(pages []string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, urlStr := range pages {
+		if _, ok := p.queued[urlStr]; ok {
+			continue
+		}
+		select {
+		case p.queue <- urlStr:
+			p.queued[urlStr] = make(chan struct{})
+		default:
+			// The queue is full, the crawl will fetch this page itself
+		}
+	}
+}
+
+// linkedPages returns the archive pages doc links to, in the order of the links,
+// without fragments. A page linked more than once is returned more than once.
+func linkedPages(doc *goquery.Document) []string {
+	var pages []string
+	doc.Find("a[href]").Each(func(_ int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		urlStr, err := vaticanURL(href)
+		if err != nil {
+			return
+		}
+		urlStr, _, _ = strings.Cut(urlStr, "#")
+		if u, err := url.Parse(urlStr); err != nil || !rePageLink.MatchString(u.Path) {
+			return
+		}
+		pages = append(pages, urlStr)
+	})
+	return pages
+}
+
+// wait blocks until urlStr has been prefetched, if it was queued, so that the
+// crawl doesn't fetch it a second time
+func (p *prefetcher) wait(urlStr string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	done := p.queued[urlStr]
+	p.mu.Unlock()
+	if done == nil {
+		return
+	}
+	select {
+	case <-done:
+	case <-p.ctx.Done():
+	}
+}
+
+// stop abandons whatever is left in the queue
+func (p *prefetcher) stop() {
+	if p == nil {
+		return
+	}
+	p.cancel()
+	p.mu.Lock()
+	close(p.queue)
+	p.mu.Unlock()
+}
+
+func (p *prefetcher) done(urlStr string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	close(p.queued[urlStr])
+}
+
+// fetch is like getOnce, but only caches the page, and gives up quietly on
+// errors instead of exiting, since the crawl will fetch the page itself if it needs it
+func (p *prefetcher) fetch(urlStr string) {
+	if _, cached := pageCache.Get(urlStr); cached {
+		return
+	}
+	atomic.AddInt64(&cacheMisses, 1)
+	res, err := httpClient.Do(newRequest(p.ctx, "GET", urlStr))
+	if err != nil {
+		verbosef("warning: couldn't prefetch %s: %s", urlStr, err)
+		return
+	}
+	defer closeBody(res)
+	if res.StatusCode != http.StatusOK {
+		verbosef("warning: couldn't prefetch %s: %s", urlStr, res.Status)
+		return
+	}
+	data, err := httputil.DumpResponse(res, true)
+	if err == nil {
+		err = pageCache.Put(urlStr, data)
+	}
+	if err != nil {
+		verbosef("warning: couldn't prefetch %s: %s", urlStr, err)
+		return
+	}
+	verbosef("prefetched %s", urlStr)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// slowTransport serves pages from memory, each one's body keyed by its URL,
+// after waiting as long as a round trip to the archive might take
+type slowTransport struct {
+	pages   map[string]string
+	latency time.Duration
+}
+
+func (t slowTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	select {
+	case <-time.After(t.latency):
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	body, ok := t.pages[req.URL.String()]
+	if !ok {
+		return nil, fmt.Errorf("no page at %s", req.URL)
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// BenchmarkPrefetch compares crawling pages that aren't cached yet one at a
+// time against crawling them with --prefetch, from an archive that takes a
+// while to answer each request. Every page links to all of them, like the
+// archive's pages link to the pages after them.
+func BenchmarkPrefetch(b *testing.B) {
+	const numPages = 20
+	var order []string
+	pages := make(map[string]string)
+	for i := 1; i <= numPages; i++ {
+		order = append(order, fmt.Sprintf("https://example.org/__P%d.HTM", i))
+	}
+	for i, urlStr := range order {
+		pages[urlStr] = fmt.Sprintf("<p>%d The paragraph on page %d.</p>", i+1, i+1)
+	}
+
+	cache, client, workers := pageCache, httpClient, *prefetchWorkers
+	defer func() { pageCache, httpClient, *prefetchWorkers = cache, client, workers }()
+	httpClient = &http.Client{Transport: slowTransport{pages: pages, latency: 5 * time.Millisecond}}
+
+	for _, bench := range []struct {
+		name    string
+		workers int
+	}{
+		{"one at a time", 0},
+		{"prefetch 4", 4},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			*prefetchWorkers = bench.workers
+			for i := 0; i < b.N; i++ {
+				// Every crawl starts with nothing cached
+				b.StopTimer()
+				useMemoryCache()
+				crawler := &Crawler{
+					Start:       order[0],
+					NextPage:    fakeNextPage(order...),
+					LinkedPages: func(*goquery.Document) []string { return order },
+					Parser:      NewVaticanParser(),
+				}
+				b.StartTimer()
+				c, _, _ := crawler.Crawl(context.Background(), nil)
+				if len(c.Paragraphs) != numPages {
+					b.Fatalf("found %d paragraphs, want %d", len(c.Paragraphs), numPages)
+				}
+			}
+		})
+	}
+}
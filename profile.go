@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+var cpuProfile = flag.String("cpuprofile", "", "write a CPU profile to `file`")
+var memProfile = flag.String("memprofile", "", "write a memory profile to `file` before exiting")
+
+// startProfiling starts the profiles asked for by --cpuprofile and --memprofile,
+// and returns a function that finishes writing them
+func startProfiling() func() {
+	var cpuFile *os.File
+	if *cpuProfile != "" {
+		var err error
+		cpuFile, err = os.Create(*cpuProfile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error creating CPU profile: %s\n", err)
+			os.Exit(1)
+		}
+		if err := pprof.StartCPUProfile(cpuFile); err != nil {
+			fmt.Fprintf(os.Stderr, "error starting CPU profile: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	return func() {
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			cpuFile.Close()
+		}
+		if *memProfile != "" {
+			file, err := os.Create(*memProfile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error creating memory profile: %s\n", err)
+				return
+			}
+			defer file.Close()
+			// Collect garbage first, so the profile shows what is really still in use
+			runtime.GC()
+			if err := pprof.WriteHeapProfile(file); err != nil {
+				fmt.Fprintf(os.Stderr, "error writing memory profile: %s\n", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"flag"
+	"regexp"
+	"strings"
+)
+
+var normalizeRefs = flag.Bool("normalize-refs", false, "print paragraph references as \"CCC 485\" rather than \"485\"")
+
+// References are cited in parentheses at the end of a sentence, separated by
+// semicolons, like "(Lk 1:26-38; cf. 485)". Each reference is classified as
+// either scripture or another paragraph of the catechism, never both:
+//
+//  1. "cf." and "see" in front of a reference are ignored.
+//  2. A reference starting with "CCC" is a paragraph ("CCC 484", "CCC §484").
+//  3. A reference starting with a book abbreviation is scripture ("Lk 1:26",
+//     "1 Cor 13:4"). The book wins over anything that comes after it.
+//  4. A reference with a chapter and verse but no book is scripture from the
+//     same book as the reference before it ("Mt 5:3; 6:2").
+//  5. A reference that is only numbers is a paragraph ("485", "484-486").
+//  6. Anything else, like a church document ("LG 56"), is in neither list.
+//
+// The references are then put in a canonical form, so that "cf. CCC 484 – 486"
+// and "484-486" are both "484-486", and "Lk. 1: 26 – 38" is "Lk 1:26-38", see
+// normalizeScripture. The references as they were written are kept in RawReferences.
+var (
+	reParenthesized = regexp.MustCompile(`\(([^()]*)\)`)
+	reSeeAlso       = regexp.MustCompile(`^(?i:cf\.?|see)\s+`)
+	reCCCRef        = regexp.MustCompile(`^(?i:CCC)(?:\s+|\s*[§¶]+\s*)(\d+(?:\s*[-‐‑‒–—]\s*\d+)?(?:\s*,\s*\d+(?:\s*[-‐‑‒–—]\s*\d+)?)*)$`)
+	// Book abbreviations are capitalized but not all capitals, which keeps out
+	// church documents like "LG" and "DV"
+	reBookRef     = regexp.MustCompile(`^((?:[1-3]\s*)?\p{Lu}\p{Ll}+\.?)\s+(\d+(?::\d+)?.*)$`)
+	reChapterRef  = regexp.MustCompile(`^\d+\s*:\s*\d+`)
+	reNumbersOnly = regexp.MustCompile(`^\d+(?:\s*[-‐‑‒–—]\s*\d+)?(?:\s*,\s*\d+(?:\s*[-‐‑‒–—]\s*\d+)?)*$`)
+)
+
+// A classifiedRef is one reference from the text and the rule that classified it
+type classifiedRef struct {
+	Raw  string   // the reference as it appears in the text, like "cf. 485"
+	Kind string   // "paragraph", "scripture" or "" for neither
+	Refs []string // what it adds to References or ScriptureRefs
+	Rule string   // which of the rules above decided Kind
+}
+
+// extractReferences returns the paragraph and scripture references cited in
+// text, and the raw text of each of them
+func extractReferences(text string) (paragraphs, scripture, raw []string) {
+	for _, ref := range classifyReferences(text) {
+		switch ref.Kind {
+		case "paragraph":
+			paragraphs = append(paragraphs, ref.Refs...)
+		case "scripture":
+			scripture = append(scripture, ref.Refs...)
+		default:
+			continue
+		}
+		raw = append(raw, ref.Raw)
+	}
+	return paragraphs, scripture, raw
+}
+
+// classifyReferences finds every reference in text and classifies it by the rules above
+func classifyReferences(text string) []classifiedRef {
+	var refs []classifiedRef
+	for _, group := range reParenthesized.FindAllStringSubmatch(text, -1) {
+		book := ""
+		for _, raw := range strings.Split(group[1], ";") {
+			refs = append(refs, classifyReference(raw, &book))
+		}
+	}
+	return refs
+}
+
+// classifyReference classifies one reference from a parenthesized group. book is
+// the book of the last scripture reference before it in the group, and is
+// updated if raw names a new one.
+func classifyReference(raw string, book *string) classifiedRef {
+	raw = strings.TrimSpace(raw)
+	ref := strings.TrimRight(reSeeAlso.ReplaceAllString(raw, ""), ".,")
+	c := classifiedRef{Raw: raw}
+	if m := reCCCRef.FindStringSubmatch(ref); m != nil {
+		c.Kind, c.Refs, c.Rule = "paragraph", splitNumbers(m[1]), "starts with CCC"
+	} else if m := reBookRef.FindStringSubmatch(ref); m != nil {
+		*book = m[1]
+		c.Kind, c.Refs, c.Rule = "scripture", []string{normalizeScripture(*book, m[2])}, "starts with the book "+*book
+	} else if *book != "" && reChapterRef.MatchString(ref) {
+		c.Kind, c.Refs, c.Rule = "scripture", []string{normalizeScripture(*book, ref)}, "chapter and verse after the book "+*book
+	} else if reNumbersOnly.MatchString(ref) {
+		c.Kind, c.Refs, c.Rule = "paragraph", splitNumbers(ref), "only numbers"
+	} else {
+		c.Rule = "not scripture or a paragraph"
+	}
+	return c
+}
+
+// A textSpan is a piece of a paragraph's text, see referenceSpans
+type textSpan struct {
+	Text string
+	Nums []int // the paragraphs this piece cites, if it's a paragraph reference
+}
+
+// Matches a paragraph number or range in a paragraph reference, like "485" or "484 – 486"
+var reReferenceNumbers = regexp.MustCompile(`\d+(?:\s*[-‐‑‒–—]\s*\d+)?`)
+
+// referenceSpans splits text into the numbers and ranges of its paragraph
+// references, like the "485" in "(cf. CCC 485)", and the text around them, so
+// exports can link them to the paragraphs they cite. Joining the spans' Text
+// gives back text.
+func referenceSpans(text string) []textSpan {
+	var spans []textSpan
+	last := 0
+	for _, group := range reParenthesized.FindAllStringSubmatchIndex(text, -1) {
+		book := ""
+		start := group[2]
+		for _, raw := range strings.Split(text[group[2]:group[3]], ";") {
+			end := start + len(raw)
+			if classifyReference(raw, &book).Kind == "paragraph" {
+				for _, m := range reReferenceNumbers.FindAllStringIndex(raw, -1) {
+					ref := text[start+m[0] : start+m[1]]
+					spans = append(spans,
+						textSpan{Text: text[last : start+m[0]]},
+						textSpan{Text: ref, Nums: referencedNumbers(splitNumbers(ref)[0])})
+					last = start + m[1]
+				}
+			}
+			start = end + 1
+		}
+	}
+	return append(spans, textSpan{Text: text[last:]})
+}
+
+// linkTarget returns the first of the paragraphs a reference cites that's in
+// paragraphs, to link the reference to, or false if none of them are
+func linkTarget(nums []int, paragraphs map[int]Paragraph) (int, bool) {
+	for _, num := range nums {
+		if _, found := paragraphs[num]; found {
+			return num, true
+		}
+	}
+	return 0, false
+}
+
+// citedParagraphs parses a citation of paragraphs the way people paste them,
+// like "CCC 484", "CCC §484", "§§484-486" or "(cf. 484)", into the same specs
+// the references are stored as, like "484" and "484-486". It uses the rules for
+// references, so whatever would be a paragraph reference in the text is accepted.
+func citedParagraphs(citation string) ([]string, bool) {
+	ref := strings.Trim(strings.TrimSpace(citation), "\"'“”‘’()[].,;: ")
+	ref = reSeeAlso.ReplaceAllString(ref, "")
+	if m := reCCCRef.FindStringSubmatch(ref); m != nil {
+		return splitNumbers(m[1]), true
+	}
+	if ref = strings.TrimSpace(strings.TrimLeft(ref, "§¶")); reNumbersOnly.MatchString(ref) {
+		return splitNumbers(ref), true
+	}
+	return nil, false
+}
+
+// splitNumbers splits "484, 486 – 487" into "484" and "486-487"
+func splitNumbers(list string) []string {
+	var numbers []string
+	for _, n := range strings.Split(list, ",") {
+		numbers = append(numbers, strings.Join(strings.Fields(reDash.ReplaceAllString(n, "-")), ""))
+	}
+	return numbers
+}
+
+var (
+	reDash = regexp.MustCompile(`\s*[-‐‑‒–—]\s*`)
+	// Spaces around the punctuation between chapters and verses, like "5: 3" or "3 , 5"
+	reVersePunct   = regexp.MustCompile(`\s*([:,.])\s*`)
+	reNumberedBook = regexp.MustCompile(`^([1-3])\s*`)
+)
+
+// normalizeScripture puts a scripture reference in the form "Book C:V-V", with
+// one space after a numbered book's number ("1 Cor"), no period after the book,
+// hyphens for dashes, no spaces around a colon and one after a comma, like
+// "1 Cor 13:4-7, 13".
+func normalizeScripture(book, rest string) string {
+	book = reNumberedBook.ReplaceAllString(strings.TrimSuffix(book, "."), "$1 ")
+	rest = strings.TrimRight(strings.TrimSpace(rest), ".,")
+	rest = reDash.ReplaceAllString(rest, "-")
+	rest = reVersePunct.ReplaceAllStringFunc(rest, func(punct string) string {
+		if punct = strings.TrimSpace(punct); punct == "," {
+			return ", "
+		}
+		return punct
+	})
+	return book + " " + strings.Join(strings.Fields(rest), " ")
+}
+
+// cccRefs returns paragraph references as they're cited, like "CCC 485"
+func cccRefs(refs []string) []string {
+	cited := make([]string, len(refs))
+	for i, ref := range refs {
+		cited[i] = "CCC " + ref
+	}
+	return cited
+}
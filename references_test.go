@@ -0,0 +1,84 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// One case for each of the rules in references.go, in order
+func TestClassifyReferences(t *testing.T) {
+	tests := []struct {
+		rule string
+		text string
+		want []classifiedRef
+	}{
+		{"1: cf. and see are ignored", "(cf. 485; see Lk 1:26)", []classifiedRef{
+			{Raw: "cf. 485", Kind: "paragraph", Refs: []string{"485"}, Rule: "only numbers"},
+			{Raw: "see Lk 1:26", Kind: "scripture", Refs: []string{"Lk 1:26"}, Rule: "starts with the book Lk"},
+		}},
+		{"2: CCC is a paragraph", "(CCC 484; CCC §484-486)", []classifiedRef{
+			{Raw: "CCC 484", Kind: "paragraph", Refs: []string{"484"}, Rule: "starts with CCC"},
+			{Raw: "CCC §484-486", Kind: "paragraph", Refs: []string{"484-486"}, Rule: "starts with CCC"},
+		}},
+		{"3: a book is scripture", "(1 Cor 13:4; Lk 1:26-38)", []classifiedRef{
+			{Raw: "1 Cor 13:4", Kind: "scripture", Refs: []string{"1 Cor 13:4"}, Rule: "starts with the book 1 Cor"},
+			{Raw: "Lk 1:26-38", Kind: "scripture", Refs: []string{"Lk 1:26-38"}, Rule: "starts with the book Lk"},
+		}},
+		{"4: chapter and verse is the book before", "(Mt 5:3; 6:2)", []classifiedRef{
+			{Raw: "Mt 5:3", Kind: "scripture", Refs: []string{"Mt 5:3"}, Rule: "starts with the book Mt"},
+			{Raw: "6:2", Kind: "scripture", Refs: []string{"Mt 6:2"}, Rule: "chapter and verse after the book Mt"},
+		}},
+		{"5: only numbers is a paragraph", "(485; 484-486, 490)", []classifiedRef{
+			{Raw: "485", Kind: "paragraph", Refs: []string{"485"}, Rule: "only numbers"},
+			{Raw: "484-486, 490", Kind: "paragraph", Refs: []string{"484-486", "490"}, Rule: "only numbers"},
+		}},
+		{"6: anything else is neither", "(LG 56; 6:2)", []classifiedRef{
+			{Raw: "LG 56", Rule: "not scripture or a paragraph"},
+			// Without a book before it, a chapter and verse is neither too
+			{Raw: "6:2", Rule: "not scripture or a paragraph"},
+		}},
+	}
+	for _, tt := range tests {
+		if got := classifyReferences(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("rule %s: classifyReferences(%q) = %+v, want %+v", tt.rule, tt.text, got, tt.want)
+		}
+	}
+}
+
+// The ways the same reference is spelled all come out in one form
+func TestExtractReferencesVariants(t *testing.T) {
+	tests := []struct {
+		text                  string
+		paragraphs, scripture []string
+	}{
+		{"(485)", []string{"485"}, nil},
+		{"(Cf. 485)", []string{"485"}, nil},
+		{"(cf 485)", []string{"485"}, nil},
+		{"(cf. CCC 485)", []string{"485"}, nil},
+		{"(CCC §485)", []string{"485"}, nil},
+		{"(see ccc 485)", []string{"485"}, nil},
+		{"(cf. CCC 484 – 486)", []string{"484-486"}, nil},
+		{"(484—486, 490)", []string{"484-486", "490"}, nil},
+		{"(Lk 1:26-38)", nil, []string{"Lk 1:26-38"}},
+		{"(Lk. 1: 26 – 38)", nil, []string{"Lk 1:26-38"}},
+		{"(cf. Lk 1:26-38.)", nil, []string{"Lk 1:26-38"}},
+		{"(1Cor 13:4-7,13)", nil, []string{"1 Cor 13:4-7, 13"}},
+		{"(1 Cor 13 : 4 , 7)", nil, []string{"1 Cor 13:4, 7"}},
+		{"(Mt 5:3; 6 : 2)", nil, []string{"Mt 5:3", "Mt 6:2"}},
+		{"(Lk 1:26-38; cf. 485; LG 56)", []string{"485"}, []string{"Lk 1:26-38"}},
+	}
+	for _, tt := range tests {
+		paragraphs, scripture, _ := extractReferences(tt.text)
+		if !reflect.DeepEqual(paragraphs, tt.paragraphs) || !reflect.DeepEqual(scripture, tt.scripture) {
+			t.Errorf("extractReferences(%q) = %q, %q, want %q, %q", tt.text, paragraphs, scripture, tt.paragraphs, tt.scripture)
+		}
+	}
+}
+
+func TestRawReferences(t *testing.T) {
+	// The references as they were written, but without the ones that are neither kind
+	_, _, raw := extractReferences("(Lk. 1: 26 – 38; Cf. CCC 485; LG 56)")
+	if want := []string{"Lk. 1: 26 – 38", "Cf. CCC 485"}; !reflect.DeepEqual(raw, want) {
+		t.Errorf("got the raw references %q, want %q", raw, want)
+	}
+}
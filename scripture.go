@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A verseSpan is a run of verses, from one chapter and verse to another, each
+// as chapter*1000 + verse. A whole chapter runs from its verse 0 to its verse 999.
+type verseSpan struct {
+	from, to int
+}
+
+// A whole book, for a query with no chapter
+var wholeBook = verseSpan{0, 1000*1000 - 1}
+
+// A scriptureCitation is a paragraph citing the verses in spans of a book. The
+// spans are nil if the reference couldn't be parsed beyond its book.
+type scriptureCitation struct {
+	Number int
+	spans  []verseSpan
+}
+
+var (
+	// A scripture passage as it's typed to look for it, like "Lk", "lk 1" or "1 Cor 13:4-7"
+	reScriptureQuery = regexp.MustCompile(`^((?:[1-3]\s*)?\p{L}+\.?)(?:\s*(\d.*))?$`)
+	// One part of the chapters and verses of a reference, like "26", "26-38",
+	// "1:26b" or "1:14-2:3". Letters for parts of a verse, like "26b", are ignored.
+	reVerses = regexp.MustCompile(`^(?:(\d+):)?(\d+)[a-z]*(?:-(?:(\d+):)?(\d+)[a-z]*)?`)
+)
+
+// bookKey is the book of a reference, like "1 Cor", in the form it's indexed by
+func bookKey(book string) string {
+	return strings.ToLower(strings.Join(strings.Fields(strings.TrimSuffix(book, ".")), ""))
+}
+
+// parseVerses parses the chapters and verses of a reference, like "1:26-38" or
+// "13:4, 7", into spans. A reference with no verses at all, like "Ps 23", is
+// the whole chapter, and "Ps 23, 24" or "Ps 23-24" are both chapters.
+func parseVerses(rest string) ([]verseSpan, bool) {
+	var spans []verseSpan
+	chapter := 0
+	for _, part := range strings.FieldsFunc(rest, func(r rune) bool { return r == ',' || r == '.' }) {
+		m := reVerses.FindStringSubmatch(strings.Join(strings.Fields(part), ""))
+		if m == nil {
+			return nil, false
+		}
+		n := func(i int) int {
+			v, _ := strconv.Atoi(m[i])
+			return v
+		}
+		if m[1] == "" && chapter == 0 {
+			// Only chapters, like "23" or "23-24"
+			last := n(2)
+			if m[4] != "" {
+				last = n(4)
+			}
+			spans = append(spans, verseSpan{n(2) * 1000, last*1000 + 999})
+			continue
+		}
+		if m[1] != "" {
+			chapter = n(1)
+		}
+		span := verseSpan{chapter*1000 + n(2), chapter*1000 + n(2)}
+		if m[4] != "" {
+			if m[3] != "" {
+				chapter = n(3)
+			}
+			span.to = chapter*1000 + n(4)
+		}
+		spans = append(spans, span)
+	}
+	return spans, len(spans) > 0
+}
+
+// scriptureIndex maps each book, by bookKey, to the paragraphs citing it, in
+// order. It's built the first time it's needed and shared after that.
+func (c *Catechism) scriptureIndex() map[string][]scriptureCitation {
+	c.scriptureOnce.Do(func() {
+		c.scriptureIdx = make(map[string][]scriptureCitation)
+		for _, num := range sortedNumbers(c.Paragraphs) {
+			for _, ref := range c.Paragraphs[num].ScriptureRefs {
+				m := reBookRef.FindStringSubmatch(ref)
+				if m == nil {
+					continue
+				}
+				spans, _ := parseVerses(m[2])
+				key := bookKey(m[1])
+				c.scriptureIdx[key] = append(c.scriptureIdx[key], scriptureCitation{Number: num, spans: spans})
+			}
+		}
+	})
+	return c.scriptureIdx
+}
+
+// CitingScripture returns the numbers of the paragraphs that cite any of the
+// passage in query, in order. The query is a reference like the ones in the
+// text, like "Lk 1:26-38", or just a book or a chapter, like "Lk" or "Lk 1",
+// and a paragraph citing any verse of it counts, like one citing "Lk 1:30-31".
+func (c *Catechism) CitingScripture(query string) ([]int, error) {
+	m := reScriptureQuery.FindStringSubmatch(strings.TrimSpace(query))
+	if m == nil {
+		return nil, fmt.Errorf("%q isn't a scripture reference like \"Lk 1:26-38\"", query)
+	}
+	want := []verseSpan{wholeBook}
+	if m[2] != "" {
+		var ok bool
+		if want, ok = parseVerses(m[2]); !ok {
+			return nil, fmt.Errorf("can't read the chapter and verses in %q", query)
+		}
+	}
+	var nums []int
+	for _, cite := range c.scriptureIndex()[bookKey(m[1])] {
+		if len(nums) > 0 && nums[len(nums)-1] == cite.Number {
+			continue
+		}
+		if citesAny(cite, want) {
+			nums = append(nums, cite.Number)
+		}
+	}
+	return nums, nil
+}
+
+// citesAny reports whether cite overlaps any of the spans in want. A citation
+// whose verses couldn't be read only matches a whole book.
+func citesAny(cite scriptureCitation, want []verseSpan) bool {
+	for _, w := range want {
+		if cite.spans == nil && w == wholeBook {
+			return true
+		}
+		for _, s := range cite.spans {
+			if s.from <= w.to && w.from <= s.to {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// scripture prints the paragraphs that cite the passage in args, like
+// `ccc scripture Lk 1:26-38` or `ccc scripture Lk 1`
+func scripture(c *Catechism, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: ccc scripture <reference>, like \"Lk 1:26-38\" or \"Lk 1\"")
+		exit(2)
+	}
+	nums, err := c.CitingScripture(strings.Join(args, " "))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid reference: %s\n", err)
+		exit(2)
+	}
+	var ps []Paragraph
+	for _, num := range nums {
+		ps = append(ps, c.Paragraphs[num])
+	}
+	for _, p := range limitParagraphs(filterParagraphs(ps)) {
+		printMatch(p)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+var exactSearch = flag.Bool("exact", false, "search is case and accent sensitive")
+var regexSearch = flag.Bool("regex", false, "search with a regular expression instead of plain text")
+var onlyNumbers = flag.Bool("only-numbers", false, "only print the numbers of the matching paragraphs")
+var prefixSearch = flag.Bool("prefix", false, "search for words starting with each word of the query, best matches first")
+var sortResults = flag.String("sort", "number", "sort search results by `number`, or by relevance, the most matches first")
+var collateLang = flag.String("collate", "en", "with --sort relevance, break ties by the text in the collation order of `language`, like fr")
+
+// searchParagraphs returns the paragraphs containing query (or matching it with --regex),
+// sorted by number. Unless --exact is set, case and accents are ignored, so "resume" matches "Résumé".
+func searchParagraphs(paragraphs map[int]Paragraph, query string) ([]Paragraph, error) {
+	match, err := newMatcher(query)
+	if err != nil {
+		return nil, err
+	}
+	sorted := make([]Paragraph, 0, len(paragraphs))
+	for _, num := range sortedNumbers(paragraphs) {
+		sorted = append(sorted, paragraphs[num])
+	}
+
+	// Split the paragraphs into one contiguous shard per CPU. Each shard's matches
+	// stay in order, so joining them in shard order keeps the results sorted no
+	// matter which goroutine finishes first.
+	shards := runtime.GOMAXPROCS(0)
+	shardSize := (len(sorted) + shards - 1) / shards
+	results := make([][]Paragraph, shards)
+	var wg sync.WaitGroup
+	for i := 0; i < shards; i++ {
+		start, end := i*shardSize, (i+1)*shardSize
+		if start >= len(sorted) {
+			break
+		}
+		if end > len(sorted) {
+			end = len(sorted)
+		}
+		wg.Add(1)
+		go func(i int, shard []Paragraph) {
+			defer wg.Done()
+			for _, p := range shard {
+				text := p.Text
+				if !*exactSearch {
+					text = fold(text)
+				}
+				if match(text) > 0 {
+					results[i] = append(results[i], p)
+				}
+			}
+		}(i, sorted[start:end])
+	}
+	wg.Wait()
+
+	var matches []Paragraph
+	for _, result := range results {
+		matches = append(matches, result...)
+	}
+	return matches, nil
+}
+
+// newMatcher returns a function counting how many times a paragraph's text matches
+// query. Unless --exact is set the text it is given has already been folded.
+func newMatcher(query string) (func(text string) int, error) {
+	if *regexSearch {
+		if !*exactSearch {
+			// Lower casing the pattern could change its meaning (\W is not \w),
+			// so only strip the accents and let the regexp ignore case
+			query = "(?i)" + stripMarks(query)
+		}
+		re, err := regexp.Compile(query)
+		if err != nil {
+			return nil, err
+		}
+		return func(text string) int {
+			return len(re.FindAllStringIndex(text, -1))
+		}, nil
+	}
+	if !*exactSearch {
+		query = fold(query)
+	}
+	return func(text string) int {
+		return strings.Count(text, query)
+	}, nil
+}
+
+// sortByRelevance sorts matches by how many times they match query, most first.
+// Ties are broken by the text in the collation order of --collate, so that
+// accented letters sort next to the letters they're based on, then by number.
+func sortByRelevance(matches []Paragraph, query string) error {
+	tag, err := language.Parse(*collateLang)
+	if err != nil {
+		return fmt.Errorf("--collate: %w", err)
+	}
+	match, err := newMatcher(query)
+	if err != nil {
+		return err
+	}
+	scores := make(map[int]int, len(matches))
+	texts := make(map[int]string, len(matches))
+	for _, p := range matches {
+		text := p.Text
+		if !*exactSearch {
+			text = fold(text)
+		}
+		scores[p.Number] = match(text)
+		texts[p.Number] = compactText(p.Text)
+	}
+	col := collate.New(tag)
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i].Number, matches[j].Number
+		if scores[a] != scores[b] {
+			return scores[a] > scores[b]
+		}
+		if cmp := col.CompareString(texts[a], texts[b]); cmp != 0 {
+			return cmp < 0
+		}
+		return a < b
+	})
+	return nil
+}
+
+// fold lower cases s and strips its diacritics
+func fold(s string) string {
+	return strings.ToLower(stripMarks(s))
+}
+
+// stripMarks removes diacritics from s, by decomposing it and removing the combining marks
+func stripMarks(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	stripped, _, err := transform.String(t, s)
+	if err != nil {
+		return s
+	}
+	return stripped
+}
+
+// search prints every paragraph matching the query in args
+func search(c *Catechism, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: ccc search <query>")
+		exit(2)
+	}
+	query := strings.Join(args, " ")
+	if *prefixSearch {
+		if *exactSearch || *regexSearch {
+			fmt.Fprintln(os.Stderr, "--prefix can't be used with --exact or --regex")
+			exit(2)
+		}
+		// The results are already ranked
+		for _, p := range limitParagraphs(filterParagraphs(c.PrefixSearch(query))) {
+			printMatch(p)
+		}
+		return
+	}
+	matches, err := c.Search(query)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid search: %s\n", err)
+		exit(2)
+	}
+	matches = filterParagraphs(matches)
+	switch *sortResults {
+	case "number":
+	case "relevance":
+		if err := sortByRelevance(matches, query); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid search: %s\n", err)
+			exit(2)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "--sort must be number or relevance, not %q\n", *sortResults)
+		exit(2)
+	}
+	for _, p := range limitParagraphs(matches) {
+		printMatch(p)
+	}
+}
+
+func printMatch(p Paragraph) {
+	if *onlyNumbers {
+		fmt.Println(p.Number)
+	} else {
+		printParagraph(p)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// syntheticParagraphs returns n paragraphs of varied, accented text, about the
+// length of the catechism's
+func syntheticParagraphs(n int) map[int]Paragraph {
+	words := strings.Fields("grace faith créé Église charity hope mystère sacrament Spirit prière covenant Résumé law love")
+	paragraphs := make(map[int]Paragraph, n)
+	for num := 1; num <= n; num++ {
+		var b strings.Builder
+		fmt.Fprintf(&b, "%d ", num)
+		for i := 0; i < 80; i++ {
+			b.WriteString(words[(num*7+i*i)%len(words)])
+			b.WriteByte(' ')
+		}
+		paragraphs[num] = Paragraph{Number: num, Text: b.String()}
+	}
+	return paragraphs
+}
+
+// BenchmarkSearch compares searching with one shard, like a sequential scan,
+// against one shard per CPU, with a regular expression that's slow to match
+func BenchmarkSearch(b *testing.B) {
+	paragraphs := syntheticParagraphs(totalParagraphs)
+	defer func(regex bool) { *regexSearch = regex }(*regexSearch)
+	*regexSearch = true
+	const query = `(gr\w+|fa\w+)\s+\w*e\s+(hope|love)`
+	for _, bench := range []struct {
+		name  string
+		procs int
+	}{
+		{"sequential", 1},
+		{"parallel", runtime.NumCPU()},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(bench.procs))
+			for i := 0; i < b.N; i++ {
+				if _, err := searchParagraphs(paragraphs, query); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkSearchIndex compares looking the same queries up in the search index
+// against scanning every paragraph for them
+func BenchmarkSearchIndex(b *testing.B) {
+	c := &Catechism{Paragraphs: syntheticParagraphs(totalParagraphs)}
+	queries := []string{"grace", "eglise charity", "mystere sacrament spirit", "covenant resume law"}
+	b.Run("scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, query := range queries {
+				if _, err := searchParagraphs(c.Paragraphs, query); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("index", func(b *testing.B) {
+		// Built once, like the server's, so only the lookups are timed
+		c.searchIndex()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for _, query := range queries {
+				if _, err := c.Search(query); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+func TestSearchIsSorted(t *testing.T) {
+	paragraphs := syntheticParagraphs(500)
+	matches, err := searchParagraphs(paragraphs, "resume")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) == 0 {
+		t.Fatal("found nothing")
+	}
+	for i := 1; i < len(matches); i++ {
+		if matches[i-1].Number >= matches[i].Number {
+			t.Fatalf("results out of order: %d before %d", matches[i-1].Number, matches[i].Number)
+		}
+	}
+}
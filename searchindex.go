@@ -0,0 +1,170 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// A searchIndex makes repeated searches of the same catechism fast, like the
+// searches `ccc serve` answers. It keeps every paragraph's folded text, so that
+// a search doesn't fold them all again, and an inverted index from each word in
+// them to the paragraphs it's in, so that only the paragraphs that could match
+// have to be checked.
+type searchIndex struct {
+	numbers  []int          // every paragraph, in order
+	folded   map[int]string // the text of each paragraph, folded
+	postings map[string][]int
+	words    []string // the keys of postings, sorted
+}
+
+// searchIndex returns the catechism's search index, building it the first time
+// it's needed. It's shared after that, so it must not be changed.
+func (c *Catechism) searchIndex() *searchIndex {
+	c.searchOnce.Do(func() {
+		idx := &searchIndex{
+			folded:   make(map[int]string, len(c.Paragraphs)),
+			postings: make(map[string][]int),
+		}
+		idx.numbers = sortedNumbers(c.Paragraphs)
+		for _, num := range idx.numbers {
+			text := fold(c.Paragraphs[num].Text)
+			idx.folded[num] = text
+			seen := make(map[string]bool)
+			for _, word := range words(text) {
+				if !seen[word] {
+					seen[word] = true
+					idx.postings[word] = append(idx.postings[word], num)
+				}
+			}
+		}
+		for word := range idx.postings {
+			idx.words = append(idx.words, word)
+		}
+		sort.Strings(idx.words)
+		c.searchIdx = idx
+	})
+	return c.searchIdx
+}
+
+// words splits text into its words, the runs of letters and digits in it
+func words(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// candidates returns the numbers of the paragraphs that could contain the folded
+// query, in order. A paragraph containing the query has to contain every word
+// between the first and last words of the query as a whole word, so those are
+// looked up directly. The first and last words can be the ends of longer words
+// ("grace" is in "graces"), so they're looked for in every word of the index.
+func (idx *searchIndex) candidates(query string) []int {
+	qws := words(query)
+	if len(qws) == 0 {
+		// A query with no words in it, like "--", can't be narrowed down
+		return idx.numbers
+	}
+	var found map[int]bool
+	for i, qw := range qws {
+		with := make(map[int]bool)
+		add := func(nums []int) {
+			for _, num := range nums {
+				if found == nil || found[num] {
+					with[num] = true
+				}
+			}
+		}
+		if i > 0 && i < len(qws)-1 {
+			add(idx.postings[qw])
+		} else {
+			for _, word := range idx.words {
+				if strings.Contains(word, qw) {
+					add(idx.postings[word])
+				}
+			}
+		}
+		found = with
+		if len(found) == 0 {
+			return nil
+		}
+	}
+	return sortedKeys(found)
+}
+
+// sortedKeys returns the numbers in m, in order
+func sortedKeys(m map[int]bool) []int {
+	nums := make([]int, 0, len(m))
+	for num := range m {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	return nums
+}
+
+// Search returns the paragraphs containing query, in order, like searchParagraphs.
+// Plain searches that ignore case and accents use the search index; --exact and
+// --regex searches fall back to checking every paragraph.
+func (c *Catechism) Search(query string) ([]Paragraph, error) {
+	if *exactSearch || *regexSearch {
+		return searchParagraphs(c.Paragraphs, query)
+	}
+	idx := c.searchIndex()
+	query = fold(query)
+	var matches []Paragraph
+	for _, num := range idx.candidates(query) {
+		if strings.Contains(idx.folded[num], query) {
+			matches = append(matches, c.Paragraphs[num])
+		}
+	}
+	return matches, nil
+}
+
+// PrefixSearch returns the paragraphs that have a word starting with every word
+// of query, ignoring case and accents, so "euchar" finds "Eucharist" and
+// "Eucharistic" but "char" doesn't. The paragraphs with the most matching words
+// come first, and those with as many are in order.
+func (c *Catechism) PrefixSearch(query string) []Paragraph {
+	idx := c.searchIndex()
+	prefixes := words(fold(query))
+	if len(prefixes) == 0 {
+		return nil
+	}
+	var found map[int]bool
+	for _, prefix := range prefixes {
+		with := make(map[int]bool)
+		// The words are sorted, so the ones starting with prefix are all together
+		for i := sort.SearchStrings(idx.words, prefix); i < len(idx.words) && strings.HasPrefix(idx.words[i], prefix); i++ {
+			for _, num := range idx.postings[idx.words[i]] {
+				if found == nil || found[num] {
+					with[num] = true
+				}
+			}
+		}
+		found = with
+		if len(found) == 0 {
+			return nil
+		}
+	}
+
+	nums := sortedKeys(found)
+	score := make(map[int]int, len(nums))
+	for _, num := range nums {
+		for _, word := range words(idx.folded[num]) {
+			for _, prefix := range prefixes {
+				if strings.HasPrefix(word, prefix) {
+					score[num]++
+					break
+				}
+			}
+		}
+	}
+	sort.SliceStable(nums, func(i, j int) bool {
+		return score[nums[i]] > score[nums[j]]
+	})
+	matches := make([]Paragraph, len(nums))
+	for i, num := range nums {
+		matches[i] = c.Paragraphs[num]
+	}
+	return matches
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var serveAddr = flag.String("addr", "localhost:8080", "with serve, listen on `address`")
+var shutdownTimeout = flag.Duration("shutdown-timeout", 5*time.Second, "with serve, how long to wait for requests to finish when shutting down")
+
+// server serves the catechism over HTTP. A reload swaps in a freshly crawled
+// catechism, requests that are already being handled keep the one they started with.
+type server struct {
+	mu sync.RWMutex
+	c  *Catechism
+
+	reloading sync.Mutex // so that only one reload crawls at a time
+
+	inFlight int64 // requests being handled, updated with sync/atomic
+}
+
+// serve crawls the catechism and serves it on --addr until interrupted:
+//
+//	GET  /paragraphs/484  the paragraph as JSON
+//	GET  /search?q=grace  the matching paragraphs as a JSON array
+//	POST /reload          crawl again, from the cache where possible
+//	GET  /export.sql      the catechism as a SQL script, see ExportSQL
+//	GET  /metrics         counters in the Prometheus text format
+func serve(ctx context.Context) {
+	s := &server{}
+	// Without a first catechism there's nothing to serve
+	if err := s.reload(ctx); err != nil {
+		exitWithCrawlError(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/paragraphs/", s.handleParagraph)
+	mux.HandleFunc("/search", s.handleSearch)
+	mux.HandleFunc("/reload", func(w http.ResponseWriter, r *http.Request) {
+		countRequest("/reload")
+		if r.Method != http.MethodPost {
+			http.Error(w, "reload with POST", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := s.reload(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "error reloading: %s\n", err)
+			http.Error(w, fmt.Sprintf("couldn't reload, still serving the %d paragraphs loaded before: %s", len(s.catechism().Paragraphs), err), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "loaded %d paragraphs\n", len(s.catechism().Paragraphs))
+	})
+	mux.HandleFunc("/export.sql", s.handleExportSQL)
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		countRequest("/metrics")
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeMetrics(w, len(s.catechism().Paragraphs))
+	})
+
+	srv := &http.Server{Addr: *serveAddr, Handler: s.track(mux)}
+	// ListenAndServe returns as soon as Shutdown starts, so serve waits on this
+	// for the requests in flight to finish
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		<-ctx.Done()
+		draining := atomic.LoadInt64(&s.inFlight)
+		fmt.Fprintf(os.Stderr, "shutting down, waiting up to %s for %d requests to finish\n", *shutdownTimeout, draining)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			fmt.Fprintf(os.Stderr, "error shutting down: %s, %d requests were cut off\n", err, atomic.LoadInt64(&s.inFlight))
+			srv.Close()
+			return
+		}
+		fmt.Fprintf(os.Stderr, "shut down, drained %d requests\n", draining)
+	}()
+	fmt.Fprintf(os.Stderr, "serving %d paragraphs on http://%s\n", len(s.catechism().Paragraphs), *serveAddr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "error serving: %s\n", err)
+		os.Exit(1)
+	}
+	<-shutdown
+}
+
+// track counts the requests h is handling, so shutting down can say how many it waited for
+func (s *server) track(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&s.inFlight, 1)
+		defer atomic.AddInt64(&s.inFlight, -1)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// catechism returns the catechism currently being served
+func (s *server) catechism() *Catechism {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.c
+}
+
+// reload crawls the catechism again and starts serving the new copy. If the
+// crawl fails, or is interrupted by a shutdown, the old copy is kept.
+func (s *server) reload(ctx context.Context) error {
+	s.reloading.Lock()
+	defer s.reloading.Unlock()
+	start := time.Now()
+	c, err := TryLoad(ctx)
+	if err != nil {
+		return err
+	}
+	recordCrawl(time.Since(start))
+	s.mu.Lock()
+	s.c = c
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *server) handleParagraph(w http.ResponseWriter, r *http.Request) {
+	countRequest("/paragraphs")
+	num, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/paragraphs/"))
+	if err != nil {
+		http.Error(w, "expected a paragraph number, like /paragraphs/484", http.StatusBadRequest)
+		return
+	}
+	p, found := s.catechism().Paragraphs[num]
+	if !found {
+		http.Error(w, fmt.Sprintf("paragraph %d not found", num), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, p)
+}
+
+func (s *server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	countRequest("/search")
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		http.Error(w, "expected a query, like /search?q=grace", http.StatusBadRequest)
+		return
+	}
+	matches, err := s.catechism().Search(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if matches == nil {
+		matches = []Paragraph{}
+	}
+	writeJSON(w, matches)
+}
+
+// handleExportSQL exports the catechism being served. A reload while it's
+// being written swaps in a new one, but the export carries on with the one it
+// started with, so it never mixes paragraphs from two crawls.
+func (s *server) handleExportSQL(w http.ResponseWriter, r *http.Request) {
+	countRequest("/export.sql")
+	c := s.catechism()
+	start := time.Now()
+	w.Header().Set("Content-Type", "application/sql; charset=utf-8")
+	if err := c.ExportSQL(w); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing response: %s\n", err)
+		return
+	}
+	verbosef("exported %d paragraphs in %s", len(c.Paragraphs), time.Since(start).Round(time.Millisecond))
+}
+
+// writeJSON writes v as the JSON response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing response: %s\n", err)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestReloadKeepsCatechismOnError(t *testing.T) {
+	fakeArchive(t, map[string]string{
+		vaticanFirstPage: "<p>1 God, infinitely perfect and blessed in himself, in a plan of sheer goodness.</p>",
+	})
+	s := &server{}
+	if err := s.reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	loaded := s.catechism()
+
+	// A reload that's interrupted by a shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.reload(ctx); !errors.Is(err, errInterrupted) {
+		t.Errorf("an interrupted reload returned %v, want %v", err, errInterrupted)
+	}
+	// One that finds a cached page that can't be read
+	if err := pageCache.Put(vaticanFirstPage, []byte("not a response")); err != nil {
+		t.Fatal(err)
+	}
+	var badCache *cacheError
+	if err := s.reload(context.Background()); !errors.As(err, &badCache) {
+		t.Errorf("a reload of a bad cached page returned %v, want a cacheError", err)
+	}
+	if s.catechism() != loaded {
+		t.Error("a failed reload replaced the catechism being served")
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var exportSQL = flag.Bool("sql", false, "export as a SQL script of the tables and inserts, to load into a database with sqlite3")
+
+// The tables ExportSQL creates. A paragraph's points and references are in
+// tables of their own, in order, since a column can only hold one value.
+const sqlSchema = `CREATE TABLE paragraphs (
+  number INTEGER PRIMARY KEY,
+  text TEXT NOT NULL,
+  part TEXT,
+  section TEXT,
+  chapter TEXT,
+  article TEXT,
+  sub_article TEXT,
+  in_brief INTEGER NOT NULL,
+  source_url TEXT
+);
+CREATE TABLE points (
+  number INTEGER NOT NULL REFERENCES paragraphs(number),
+  position INTEGER NOT NULL,
+  text TEXT NOT NULL
+);
+CREATE TABLE citations (
+  number INTEGER NOT NULL REFERENCES paragraphs(number),
+  position INTEGER NOT NULL,
+  kind TEXT NOT NULL, -- 'paragraph' or 'scripture'
+  ref TEXT NOT NULL
+);
+`
+
+// ExportSQL writes a SQL script that creates the tables in sqlSchema and
+// inserts every paragraph, for `sqlite3 ccc.db < ccc.sql`. The inserts are in a
+// single transaction, since sqlite3 would otherwise commit each of them on its
+// own, which for thousands of rows is slow.
+//
+// The rows are all read from c before anything is written, so the script is of
+// one snapshot of the catechism. A Catechism isn't changed once it's loaded, a
+// new crawl loads a new one (see server.reload), so that snapshot is consistent.
+func (c *Catechism) ExportSQL(w io.Writer) error {
+	var ps []Paragraph
+	for _, num := range sortedNumbers(c.Paragraphs) {
+		ps = append(ps, displayed(c.Paragraphs[num]))
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "BEGIN TRANSACTION;")
+	fmt.Fprint(bw, sqlSchema)
+	for _, p := range ps {
+		loc := p.levels()
+		inBrief := 0
+		if p.InBrief {
+			inBrief = 1
+		}
+		fmt.Fprintf(bw, "INSERT INTO paragraphs VALUES (%d, %s, %s, %s, %s, %s, %s, %d, %s);\n",
+			p.Number, sqlString(p.Text), sqlNullable(loc.Part), sqlNullable(loc.Section), sqlNullable(loc.Chapter),
+			sqlNullable(loc.Article), sqlNullable(loc.SubArticle), inBrief, sqlNullable(p.SourceURL))
+		for i, point := range p.Points {
+			fmt.Fprintf(bw, "INSERT INTO points VALUES (%d, %d, %s);\n", p.Number, i+1, sqlString(point))
+		}
+		for i, ref := range p.References {
+			fmt.Fprintf(bw, "INSERT INTO citations VALUES (%d, %d, 'paragraph', %s);\n", p.Number, i+1, sqlString(ref))
+		}
+		for i, ref := range p.ScriptureRefs {
+			fmt.Fprintf(bw, "INSERT INTO citations VALUES (%d, %d, 'scripture', %s);\n", p.Number, len(p.References)+i+1, sqlString(ref))
+		}
+	}
+	fmt.Fprintln(bw, "COMMIT;")
+	return bw.Flush()
+}
+
+// sqlString quotes s as an SQL string literal, doubling any quotes in it
+func sqlString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// sqlNullable is sqlString, but NULL for an empty string
+func sqlNullable(s string) string {
+	if s == "" {
+		return "NULL"
+	}
+	return sqlString(s)
+}
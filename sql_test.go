@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExportSQL(t *testing.T) {
+	c := &Catechism{Paragraphs: map[int]Paragraph{
+		485: {Number: 485, Text: "485 The Spirit's mission", References: []string{"484"}},
+		484: {Number: 484, Text: "484 The Annunciation", ScriptureRefs: []string{"Lk 1:26-38"}, Points: []string{"one"}},
+	}}
+	var sb strings.Builder
+	if err := c.ExportSQL(&sb); err != nil {
+		t.Fatal(err)
+	}
+	script := sb.String()
+	// Every insert is in the one transaction
+	if !strings.HasPrefix(script, "BEGIN TRANSACTION;\n") || !strings.HasSuffix(script, "COMMIT;\n") || strings.Count(script, "COMMIT;") != 1 {
+		t.Errorf("the script isn't a single transaction:\n%s", script)
+	}
+	for _, want := range []string{
+		"INSERT INTO paragraphs VALUES (484, '484 The Annunciation', NULL, NULL, NULL, NULL, NULL, 0, NULL);",
+		// Quotes in the text are doubled
+		"INSERT INTO paragraphs VALUES (485, '485 The Spirit''s mission',",
+		"INSERT INTO points VALUES (484, 1, 'one');",
+		"INSERT INTO citations VALUES (484, 1, 'scripture', 'Lk 1:26-38');",
+		"INSERT INTO citations VALUES (485, 1, 'paragraph', '484');",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("the script doesn't have %q:\n%s", want, script)
+		}
+	}
+	if strings.Index(script, "(484,") > strings.Index(script, "(485,") {
+		t.Errorf("the paragraphs aren't in order:\n%s", script)
+	}
+}
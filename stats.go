@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+var topCount = flag.Int("top", 10, "with stats --refs, list the `N` paragraphs with the most references")
+
+// stats prints how many paragraphs and references the crawl found, and with
+// --refs the paragraphs referenced the most, and those that reference the most
+func stats(c *Catechism) {
+	if *topCount < 1 {
+		fmt.Fprintln(os.Stderr, "--top must be at least 1")
+		exit(2)
+	}
+	var inBrief, withRefs, refs, scripture int
+	outbound := make(map[int]int)
+	inbound := make(map[int]int)
+	for _, num := range sortedNumbers(c.Paragraphs) {
+		p := c.Paragraphs[num]
+		if p.InBrief {
+			inBrief++
+		}
+		if len(p.References) > 0 {
+			withRefs++
+		}
+		refs += len(p.References)
+		scripture += len(p.ScriptureRefs)
+		// Like InboundRefs, a paragraph referenced twice by another counts once
+		seen := make(map[int]bool)
+		for _, ref := range p.References {
+			for _, to := range referencedNumbers(ref) {
+				seen[to] = true
+			}
+		}
+		outbound[num] = len(seen)
+		if n := len(c.InboundRefs(num)); n > 0 {
+			inbound[num] = n
+		}
+	}
+	fmt.Printf("paragraphs:           %d\n", len(c.Paragraphs))
+	fmt.Printf("in brief:             %d\n", inBrief)
+	fmt.Printf("paragraph references: %d, in %d paragraphs\n", refs, withRefs)
+	fmt.Printf("scripture references: %d\n", scripture)
+	if !*verifyRefs {
+		return
+	}
+	fmt.Println()
+	fmt.Println("most referenced:")
+	printTop(inbound, c.Paragraphs)
+	fmt.Println("most references:")
+	printTop(outbound, c.Paragraphs)
+}
+
+// printTop prints the --top paragraphs with the highest counts, and the lowest
+// numbers first among equal counts, as "number  count  the start of the text"
+func printTop(counts map[int]int, paragraphs map[int]Paragraph) {
+	var nums []int
+	for num, n := range counts {
+		if n > 0 {
+			nums = append(nums, num)
+		}
+	}
+	sort.Slice(nums, func(i, j int) bool {
+		if counts[nums[i]] != counts[nums[j]] {
+			return counts[nums[i]] > counts[nums[j]]
+		}
+		return nums[i] < nums[j]
+	})
+	if len(nums) > *topCount {
+		nums = nums[:*topCount]
+	}
+	if len(nums) == 0 {
+		fmt.Println("  (none)")
+		return
+	}
+	for _, num := range nums {
+		fmt.Printf("  %4d  %3d  %s\n", num, counts[num], snippet(compactText(displayed(paragraphs[num]).Text)))
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package main
+
+import (
+	"os"
+	"strconv"
+)
+
+// terminalWidth returns how many columns wide the terminal is, from $COLUMNS
+// since there's no ioctl to ask, or 0 if it can't tell
+func terminalWidth(f *os.File) int {
+	cols, err := strconv.Atoi(os.Getenv("COLUMNS"))
+	if err != nil {
+		return 0
+	}
+	return cols
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"regexp"
+	"strings"
+)
+
+var (
+	trimText   = flag.Bool("trim", false, "remove footnote numbers and bracketed markers from the text")
+	asciiText  = flag.Bool("ascii", false, "replace smart quotes, dashes and ellipses with ASCII")
+	asciiRunes = strings.NewReplacer(
+		"‘", "'", "’", "'", "‚", "'", "‛", "'",
+		"“", `"`, "”", `"`, "„", `"`, "‟", `"`,
+		"«", `"`, "»", `"`,
+		"‐", "-", "‑", "-", "‒", "-", "–", "-", "—", "--", "―", "--",
+		"…", "...",
+		"\u00a0", " ",
+	)
+)
+
+// A footnote number follows a word and its punctuation with no space, like
+// the 13 in `gave him:13 he is`. Requiring a letter before the punctuation keeps
+// verse numbers like the 3 in "Mt 5:3" intact.
+var reFootnote = regexp.MustCompile(`(\p{L}[.,;:!?"'”’)\]]+)\d{1,3}(\s|$)`)
+
+// Editorial markers are bracketed numbers like "[12]"
+var reBracketMarker = regexp.MustCompile(`\s*\[\d+\]`)
+
+// trimmed removes footnote numbers and editorial markers from text
+func trimmed(text string) string {
+	text = reFootnote.ReplaceAllString(text, "$1$2")
+	return reBracketMarker.ReplaceAllString(text, "")
+}
+
+// displayed returns p with its text changed the way the output flags ask for,
+// like --trim. Only the copy that gets printed is changed.
+func displayed(p Paragraph) Paragraph {
+	// Whatever the page had in it, print valid UTF-8 with no byte order marks
+	p.Text = strings.ReplaceAll(strings.ToValidUTF8(p.Text, "\uFFFD"), "\uFEFF", "")
+	if *trimText {
+		p.Text = trimmed(p.Text)
+	}
+	if *asciiText {
+		p.Text = asciiRunes.Replace(p.Text)
+	}
+	if *normalizeRefs {
+		p.References = cccRefs(p.References)
+	}
+	return p
+}
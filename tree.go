@@ -0,0 +1,464 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/text/unicode/norm"
+)
+
+// A Catechism is the whole text, both as a tree of parts and as paragraphs by number
+type Catechism struct {
+	Parts      []Part
+	Paragraphs map[int]Paragraph
+
+	duplicates  []int         // numbers that were found more than once, see problems
+	failedPages []string      // pages that couldn't be fetched, and were skipped
+	emptyPages  []string      // pages without a single <p>, so nothing on them could be parsed
+	plan        []crawledPage // the pages in the order they were crawled, see crawlCommand
+	lang        string        // the edition's language code, or "" for the English one
+
+	inboundOnce   sync.Once
+	inbound       map[int][]int // see InboundRefs
+	searchOnce    sync.Once
+	searchIdx     *searchIndex // see searchIndex
+	scriptureOnce sync.Once
+	scriptureIdx  map[string][]scriptureCitation // see scriptureIndex
+}
+
+// location is where a paragraph is in the tree, given by the titles of the divisions it is in
+type location struct {
+	part       string
+	section    string
+	chapter    string
+	article    string
+	subArticle string
+}
+
+// Headings for each level of the tree. They are matched against the heading text
+// with line breaks turned into spaces, like "PART ONE THE PROFESSION OF FAITH".
+var (
+	rePartHeading       = regexp.MustCompile(`^PART\s+(ONE|TWO|THREE|FOUR)\b`)
+	reSectionHeading    = regexp.MustCompile(`^SECTION\s+(ONE|TWO|THREE)\b`)
+	reChapterHeading    = regexp.MustCompile(`^CHAPTER\s+(ONE|TWO|THREE|FOUR)\b`)
+	reArticleHeading    = regexp.MustCompile(`^ARTICLE\s+(\d+)\b`)
+	reSubArticleHeading = regexp.MustCompile(`^(Paragraph\s+\d+\b|[IVX]+\.\s)`)
+)
+
+// heading updates the current location if title is the heading of a new division.
+// A new division also ends any "IN BRIEF" section.
+func (state *parseState) heading(title string) {
+	loc := state.loc
+	switch {
+	case rePartHeading.MatchString(title):
+		state.loc = location{part: title}
+	case reSectionHeading.MatchString(title):
+		state.loc = location{part: loc.part, section: title}
+	case reChapterHeading.MatchString(title):
+		state.loc = location{part: loc.part, section: loc.section, chapter: title}
+	case reArticleHeading.MatchString(title):
+		state.loc = location{part: loc.part, section: loc.section, chapter: loc.chapter, article: title}
+	case reSubArticleHeading.MatchString(title):
+		state.loc.subArticle = title
+	default:
+		return
+	}
+	state.inBrief = false
+}
+
+// articleNumber returns the number in an article heading like "ARTICLE 3 THE HOLY SPIRIT",
+// or 0 if there isn't one
+func articleNumber(title string) int {
+	matches := reArticleHeading.FindStringSubmatch(title)
+	if matches == nil {
+		return 0
+	}
+	num, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return 0
+	}
+	return num
+}
+
+// Location returns the titles of the part, section, chapter, article and
+// sub-article containing p, leaving out any that are untitled
+func (p Paragraph) Location() []string {
+	var titles []string
+	loc := p.levels()
+	for _, title := range []string{loc.Part, loc.Section, loc.Chapter, loc.Article, loc.SubArticle} {
+		if title != "" {
+			titles = append(titles, title)
+		}
+	}
+	return titles
+}
+
+// A paragraphLocation is the title of each level of the tree containing a
+// paragraph, as `ccc path --json` prints it. Untitled levels are left out.
+type paragraphLocation struct {
+	Number     int    `json:"number"`
+	Part       string `json:"part,omitempty"`
+	Section    string `json:"section,omitempty"`
+	Chapter    string `json:"chapter,omitempty"`
+	Article    string `json:"article,omitempty"`
+	SubArticle string `json:"subarticle,omitempty"`
+}
+
+// levels returns the titles of the levels of the tree containing p
+func (p Paragraph) levels() paragraphLocation {
+	loc := paragraphLocation{Number: p.Number}
+	if p.Parent == nil {
+		return loc
+	}
+	subArticle := p.Parent
+	article := subArticle.Parent
+	chapter := article.Parent
+	section := chapter.Parent
+	part := section.Parent
+	loc.Part, loc.Section, loc.Chapter = part.Title, section.Title, chapter.Title
+	loc.Article, loc.SubArticle = article.Title, subArticle.Title
+	return loc
+}
+
+// printLocation prints where p is in the tree, as breadcrumbs or with --json as an object
+func printLocation(p Paragraph) {
+	if *jsonOutput {
+		data, err := json.Marshal(p.levels())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error encoding the location of paragraph %d: %s\n", p.Number, err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+		return
+	}
+	fmt.Println(strings.Join(p.Location(), " > "))
+}
+
+// SectionTitle returns the title of the section p is in, or "" if it isn't in one
+func (p Paragraph) SectionTitle() string {
+	return p.sectionTitle
+}
+
+// ChapterTitle returns the title of the chapter p is in, or "" if it isn't in one
+func (p Paragraph) ChapterTitle() string {
+	return p.chapterTitle
+}
+
+// Citation returns how p is cited, like "CCC 484 (Article 1)"
+func (p Paragraph) Citation() string {
+	if p.ArticleNumber == 0 {
+		return fmt.Sprintf("CCC %d", p.Number)
+	}
+	return fmt.Sprintf("CCC %d (Article %d)", p.Number, p.ArticleNumber)
+}
+
+// headingText is like s.Text(), except that line breaks become spaces, since
+// headings like "PART ONE<br>THE PROFESSION OF FAITH" are split over two lines
+func headingText(s *goquery.Selection) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			b.WriteString(n.Data)
+		case n.Type == html.ElementNode && n.Data == "br":
+			b.WriteString(" ")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	for _, n := range s.Nodes {
+		walk(n)
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// Load crawls the English catechism from the first page, following "Next" links
+// (and reading from the cache where possible), and builds the tree of its parts
+func Load(ctx context.Context) *Catechism {
+	c, err := TryLoad(ctx)
+	if err != nil {
+		exitWithCrawlError(err)
+	}
+	return c
+}
+
+// TryLoad is Load, but returns an error instead of exiting, see TryCrawl
+func TryLoad(ctx context.Context) (*Catechism, error) {
+	// Pages read from a directory aren't the Vatican's, so they aren't indexed
+	if *fromDir != "" {
+		return tryLoadDir(*fromDir)
+	}
+	c, _, _, err := NewVaticanCrawler(vaticanFirstPage).TryCrawl(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Now that every page is known, save where each paragraph is for future
+	// lookups, unless some pages were skipped and their paragraphs are missing
+	if len(c.failedPages) == 0 {
+		if err := savePageIndex(buildPageIndex(c.Paragraphs)); err != nil {
+			fmt.Fprintf(os.Stderr, "error saving page index: %s\n", err)
+		}
+	}
+	return c, nil
+}
+
+// Stream crawls the catechism like Load, but sends each paragraph on the
+// returned channel as soon as its page has been parsed, in the order they're
+// found, rather than returning them all at the end. The channel is closed once
+// the crawl is done. The paragraphs aren't in the tree yet, so their Parent is nil.
+func Stream(ctx context.Context) <-chan Paragraph {
+	ch := make(chan Paragraph)
+	go func() {
+		defer close(ch)
+		if *fromDir != "" {
+			c := loadDir(*fromDir)
+			for _, num := range sortedNumbers(c.Paragraphs) {
+				ch <- c.Paragraphs[num]
+			}
+			return
+		}
+		c, _, _ := NewVaticanCrawler(vaticanFirstPage).Crawl(ctx, func(p Paragraph) {
+			ch <- p
+		})
+		if len(c.failedPages) == 0 {
+			if err := savePageIndex(buildPageIndex(c.Paragraphs)); err != nil {
+				fmt.Fprintf(os.Stderr, "error saving page index: %s\n", err)
+			}
+		}
+	}()
+	return ch
+}
+
+// failIfStrict exits with --strict if anything verify would complain about is
+// wrong with c. Unless complete, c is only part of the catechism.
+func failIfStrict(c *Catechism, complete bool) {
+	if err := strictError(c, complete); err != nil {
+		exitWithCrawlError(err)
+	}
+}
+
+// strictError is failIfStrict, but returns the problems as an error, one per line
+func strictError(c *Catechism, complete bool) error {
+	if !*strict {
+		return nil
+	}
+	problems := c.problems(complete)
+	if len(problems) == 0 {
+		return nil
+	}
+	for i, problem := range problems {
+		problems[i] = "strict: " + problem
+	}
+	return errors.New(strings.Join(problems, "\n"))
+}
+
+// A pageParser parses the pages of the catechism one after another, in order,
+// with a Parser and collects their paragraphs
+type pageParser struct {
+	parser     Parser
+	paragraphs map[int]Paragraph
+	// The paragraphs in the order they were found, which is the order of the tree
+	inOrder    []Paragraph
+	duplicates []int
+	empty      []string // pages with nothing matching --selector
+	emit       func(Paragraph)
+}
+
+// newPageParser returns a pageParser that parses pages with parser, and calls
+// emit, if it isn't nil, with each new paragraph as it's found
+func newPageParser(parser Parser, emit func(Paragraph)) *pageParser {
+	return &pageParser{parser: parser, paragraphs: make(map[int]Paragraph), emit: emit}
+}
+
+// parse parses doc, the page at urlStr that was size bytes long, warning about
+// pages that look wrong and applying --dedup-policy to numbers found again. It
+// returns how many paragraphs were on the page.
+func (pp *pageParser) parse(doc *goquery.Document, urlStr string, size int64) int {
+	found, err := pp.parser.ParsePage(doc)
+	verbosef("%s: %d bytes, %d paragraphs", urlStr, size, len(found))
+	switch err {
+	case nil:
+	case errNoParagraphElements:
+		// Every paragraph and heading is in a <p>, so a page without any
+		// is the first sign that the markup changed and the parser is broken
+		fmt.Fprintf(os.Stderr, "warning: no elements match %q on %s (%d bytes), so nothing on it can be parsed.\n", *paragraphSelector, urlStr, size)
+		fmt.Fprintln(os.Stderr, "warning: if the page looks fine in a browser, the Vatican's markup may have changed, try --selector or update ccc")
+		pp.empty = append(pp.empty, urlStr)
+	case errNothingFound:
+		// Neither paragraphs nor headings, which is what an error page looks like
+		fmt.Fprintf(os.Stderr, "warning: found nothing on %s (%d bytes), it may be an error page\n", urlStr, size)
+	default:
+		fmt.Fprintf(os.Stderr, "warning: couldn't parse %s: %s\n", urlStr, err)
+	}
+	for _, p := range found {
+		p = normalized(p)
+		if p.SourceURL == "" {
+			p.SourceURL = urlStr
+		}
+		_, isStoredInMap := pp.paragraphs[p.Number]
+		if !isStoredInMap {
+			pp.paragraphs[p.Number] = p
+			pp.inOrder = append(pp.inOrder, p)
+			if pp.emit != nil {
+				pp.emit(p)
+			}
+		} else {
+			verbosef("warning: paragraph %d was found again on %s", p.Number, urlStr)
+			pp.duplicates = append(pp.duplicates, p.Number)
+			if replacesDuplicate(pp.paragraphs[p.Number], p) {
+				// The tree is built in the order paragraphs were found, so
+				// the new one goes where it was found, not where the old one was
+				for i := range pp.inOrder {
+					if pp.inOrder[i].Number == p.Number {
+						pp.inOrder = append(pp.inOrder[:i], pp.inOrder[i+1:]...)
+						break
+					}
+				}
+				pp.paragraphs[p.Number] = p
+				pp.inOrder = append(pp.inOrder, p)
+			}
+		}
+	}
+	return len(found)
+}
+
+// normalized returns p with its text in Unicode's composed form (NFC), so that
+// an "é" written as "e" and a combining accent is the same as any other "é"
+// when it's searched for, hashed or compared
+func normalized(p Paragraph) Paragraph {
+	p.Text = norm.NFC.String(p.Text)
+	for _, list := range [][]string{p.Points, p.References, p.ScriptureRefs, p.RawReferences} {
+		for i := range list {
+			list[i] = norm.NFC.String(list[i])
+		}
+	}
+	for _, title := range []*string{&p.loc.part, &p.loc.section, &p.loc.chapter, &p.loc.article, &p.loc.subArticle} {
+		*title = norm.NFC.String(*title)
+	}
+	return p
+}
+
+// catechism builds the tree of the paragraphs parsed so far. fromStart says
+// they were parsed from the first page on, see buildTree.
+func (pp *pageParser) catechism(fromStart bool) *Catechism {
+	warnShortParagraphs(pp.paragraphs)
+	c := &Catechism{Paragraphs: pp.paragraphs, duplicates: pp.duplicates, emptyPages: pp.empty}
+	c.buildTree(pp.inOrder, fromStart)
+	return c
+}
+
+// nextKnownPage returns the first page linked to after failed that hasn't been
+// visited yet, to carry on the crawl from when failed's own "Next" link can't be read
+func nextKnownPage(links []string, failed string, visited map[string]bool) string {
+	for i, urlStr := range links {
+		if urlStr != failed {
+			continue
+		}
+		for _, next := range links[i+1:] {
+			if !visited[next] && !strings.EqualFold(next, vaticanIndexPage) {
+				return next
+			}
+		}
+		break
+	}
+	return ""
+}
+
+// The catechism shared by every LoadShared caller
+var (
+	sharedOnce      sync.Once
+	sharedCatechism *Catechism
+)
+
+// LoadShared is like Load, but every caller in the process gets the same
+// Catechism. The first call loads it, and any calls made meanwhile block until
+// it's done instead of crawling again. It is never reloaded, so it reflects the
+// cache as it was on the first call for the lifetime of the process; use Load to
+// get a fresh copy. The shared Catechism must not be modified.
+func LoadShared(ctx context.Context) *Catechism {
+	sharedOnce.Do(func() {
+		sharedCatechism = Load(ctx)
+	})
+	return sharedCatechism
+}
+
+// The title of the part made up of the paragraphs before Part One
+const prologueTitle = "Prologue"
+
+// buildTree groups paragraphs (in the order they appear) into the parts, sections,
+// chapters, articles and sub-articles they are in, then points every paragraph
+// at its parent. If fromStart, the paragraphs were parsed from the first page
+// on, and the ones that come before the first part, 1 to 25, go in a part of
+// their own called the Prologue. Otherwise the ones before the first heading
+// found could be anywhere, so they're left without a part.
+func (c *Catechism) buildTree(inOrder []Paragraph, fromStart bool) {
+	c.Parts = nil
+	for _, p := range inOrder {
+		if p.loc.part == "" && fromStart {
+			p.loc = location{part: prologueTitle}
+		}
+		if len(c.Parts) == 0 || c.Parts[len(c.Parts)-1].Title != p.loc.part {
+			c.Parts = append(c.Parts, Part{Title: p.loc.part})
+		}
+		part := &c.Parts[len(c.Parts)-1]
+		if len(part.Sections) == 0 || part.Sections[len(part.Sections)-1].Title != p.loc.section {
+			part.Sections = append(part.Sections, Section{Title: p.loc.section})
+		}
+		section := &part.Sections[len(part.Sections)-1]
+		if len(section.Chapters) == 0 || section.Chapters[len(section.Chapters)-1].Title != p.loc.chapter {
+			section.Chapters = append(section.Chapters, Chapter{Title: p.loc.chapter})
+		}
+		chapter := &section.Chapters[len(section.Chapters)-1]
+		if len(chapter.Articles) == 0 || chapter.Articles[len(chapter.Articles)-1].Title != p.loc.article {
+			chapter.Articles = append(chapter.Articles, Article{
+				Number: articleNumber(p.loc.article),
+				Title:  p.loc.article,
+			})
+		}
+		article := &chapter.Articles[len(chapter.Articles)-1]
+		if len(article.SubArticles) == 0 || article.SubArticles[len(article.SubArticles)-1].Title != p.loc.subArticle {
+			article.SubArticles = append(article.SubArticles, SubArticle{Title: p.loc.subArticle})
+		}
+		subArticle := &article.SubArticles[len(article.SubArticles)-1]
+		subArticle.Paragraphs = append(subArticle.Paragraphs, p)
+	}
+
+	// The slices are done growing, so the parent pointers won't move anymore
+	for pi := range c.Parts {
+		part := &c.Parts[pi]
+		for si := range part.Sections {
+			section := &part.Sections[si]
+			section.Parent = part
+			for ci := range section.Chapters {
+				chapter := &section.Chapters[ci]
+				chapter.Parent = section
+				for ai := range chapter.Articles {
+					article := &chapter.Articles[ai]
+					article.Parent = chapter
+					for ri := range article.SubArticles {
+						subArticle := &article.SubArticles[ri]
+						subArticle.Parent = article
+						for i := range subArticle.Paragraphs {
+							subArticle.Paragraphs[i].Parent = subArticle
+							subArticle.Paragraphs[i].ArticleNumber = article.Number
+							subArticle.Paragraphs[i].sectionTitle = section.Title
+							subArticle.Paragraphs[i].chapterTitle = chapter.Title
+							c.Paragraphs[subArticle.Paragraphs[i].Number] = subArticle.Paragraphs[i]
+						}
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	verifyRefs    = flag.Bool("refs", false, "with verify, also check that every paragraph reference points to a crawled paragraph, with stats, list the paragraphs with the most references")
+	strict        = flag.Bool("strict", false, "fail if anything looks wrong with the crawl, as verify --refs would report it")
+	dedupPolicy   = flag.String("dedup-policy", "first-wins", "when a paragraph number is found twice, keep the `first-wins`, last-wins or longest-wins one")
+	findDupes     = flag.Bool("dupes", false, "with verify, also check for paragraphs with the same or nearly the same text")
+	similarity    = flag.Float64("similarity", 0.75, "with verify --dupes, how alike two paragraphs' texts have to be, from 0 to 1, to be reported")
+	expectedTotal = flag.Int("expected-total", 0, "check that the paragraphs are numbered from 1 to `n`, instead of the edition's known last paragraph")
+)
+
+// The last paragraph of each edition, by language code. Every edition is numbered
+// from 1, and others can be added here once their numbering has been checked.
+var editionTotals = map[string]int{
+	"en": totalParagraphs,
+}
+
+// lastParagraph returns the number the paragraphs of the edition in lang should
+// end at: --expected-total if it's set, or else the edition's known total
+func lastParagraph(lang string) int {
+	if *expectedTotal > 0 {
+		return *expectedTotal
+	}
+	return editionTotals[lang]
+}
+
+// checkDedupPolicy validates --dedup-policy
+func checkDedupPolicy() error {
+	switch *dedupPolicy {
+	case "first-wins", "last-wins", "longest-wins":
+		return nil
+	}
+	return fmt.Errorf("--dedup-policy must be first-wins, last-wins or longest-wins, not %q", *dedupPolicy)
+}
+
+// replacesDuplicate reports whether again, found after kept with the same number, replaces it under --dedup-policy
+func replacesDuplicate(kept, again Paragraph) bool {
+	switch *dedupPolicy {
+	case "last-wins":
+		return true
+	case "longest-wins":
+		return utf8.RuneCountInString(compactText(again.Text)) > utf8.RuneCountInString(compactText(kept.Text))
+	}
+	return false
+}
+
+// toleratesDuplicates reports whether --dedup-policy was set, which says that
+// numbers appearing twice are expected, so they aren't problems
+func toleratesDuplicates() bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "dedup-policy" {
+			set = true
+		}
+	})
+	return set
+}
+
+// checkVerifyFlags validates --expected-total and --similarity
+func checkVerifyFlags() error {
+	if *expectedTotal < 0 {
+		return fmt.Errorf("--expected-total can't be negative")
+	}
+	if *similarity <= 0 || *similarity > 1 {
+		return fmt.Errorf("--similarity must be more than 0 and at most 1, not %g", *similarity)
+	}
+	return nil
+}
+
+// Paragraphs with fewer characters than this (not counting the number) are
+// most likely parse failures, since even the shortest paragraphs are a full sentence
+const minParagraphLength = 20
+
+// shortParagraphs returns the numbers of the paragraphs whose text is suspiciously short, in order
+func shortParagraphs(paragraphs map[int]Paragraph) []int {
+	var short []int
+	for _, num := range sortedNumbers(paragraphs) {
+		if utf8.RuneCountInString(compactText(paragraphs[num].Text)) < minParagraphLength {
+			short = append(short, num)
+		}
+	}
+	return short
+}
+
+// warnShortParagraphs logs each suspiciously short paragraph under --verbose
+func warnShortParagraphs(paragraphs map[int]Paragraph) {
+	for _, num := range shortParagraphs(paragraphs) {
+		verbosef("warning: paragraph %d is suspiciously short: %q", num, paragraphs[num].Text)
+	}
+}
+
+// danglingReference is a reference to a paragraph that isn't in the crawl
+type danglingReference struct {
+	From int    // the paragraph with the reference
+	Ref  string // the reference, like "485" or "484-486"
+	To   int    // the missing paragraph
+}
+
+// danglingReferences returns the paragraph references that don't resolve, in order.
+// Scripture references aren't checked.
+func danglingReferences(paragraphs map[int]Paragraph) []danglingReference {
+	var dangling []danglingReference
+	for _, num := range sortedNumbers(paragraphs) {
+		for _, ref := range paragraphs[num].References {
+			nums := referencedNumbers(ref)
+			if nums == nil {
+				dangling = append(dangling, danglingReference{From: num, Ref: ref})
+			}
+			for _, to := range nums {
+				if _, found := paragraphs[to]; !found {
+					dangling = append(dangling, danglingReference{From: num, Ref: ref, To: to})
+				}
+			}
+		}
+	}
+	return dangling
+}
+
+// Paragraphs with fewer words than this aren't compared by --dupes, since short
+// texts can be alike without anything being wrong
+const minDupeWords = 8
+
+// A duplicateText is two paragraphs whose texts are alike
+type duplicateText struct {
+	A, B       int     // the paragraph numbers, A < B
+	Similarity float64 // 1 if the texts are the same
+}
+
+// duplicateTexts returns the pairs of paragraphs whose texts are at least
+// threshold alike, in order. Texts are compared ignoring case, accents,
+// punctuation and numbers, so the paragraph's own number and its footnote
+// numbers don't count, and how alike they are is the share of their pairs of
+// consecutive words (shingles) that they have in common (the Jaccard index).
+// Changing one word in a paragraph of 20 makes it about 80% like the original.
+func duplicateTexts(paragraphs map[int]Paragraph, threshold float64) []duplicateText {
+	var dupes []duplicateText
+	// The paragraphs seen so far with each shingle
+	seen := make(map[string][]int)
+	sizes := make(map[int]int)
+	for _, num := range sortedNumbers(paragraphs) {
+		set := shingles(paragraphs[num].Text)
+		if len(set) == 0 {
+			continue
+		}
+		sizes[num] = len(set)
+		shared := make(map[int]int)
+		for s := range set {
+			for _, other := range seen[s] {
+				shared[other]++
+			}
+			seen[s] = append(seen[s], num)
+		}
+		for _, other := range sortedKeys(intKeys(shared)) {
+			n := shared[other]
+			if sim := float64(n) / float64(sizes[other]+len(set)-n); sim >= threshold {
+				dupes = append(dupes, duplicateText{A: other, B: num, Similarity: sim})
+			}
+		}
+	}
+	return dupes
+}
+
+// shingles returns the pairs of consecutive words in text, with the numbers, case and
+// accents left out, or nothing if text is shorter than minDupeWords
+func shingles(text string) map[string]bool {
+	var ws []string
+	for _, w := range words(fold(text)) {
+		if strings.IndexFunc(w, unicode.IsLetter) >= 0 {
+			ws = append(ws, strings.TrimFunc(w, unicode.IsDigit))
+		}
+	}
+	if len(ws) < minDupeWords {
+		return nil
+	}
+	set := make(map[string]bool)
+	for i := 0; i+1 < len(ws); i++ {
+		set[ws[i]+" "+ws[i+1]] = true
+	}
+	return set
+}
+
+// intKeys returns the keys of counts as a set, for sortedKeys
+func intKeys(counts map[int]int) map[int]bool {
+	keys := make(map[int]bool, len(counts))
+	for k := range counts {
+		keys[k] = true
+	}
+	return keys
+}
+
+// problems describes everything that looks wrong with the crawl: pages that
+// couldn't be fetched or had nothing to parse, numbers that appeared more than
+// once, paragraphs whose text is too short to be real, with --dupes paragraphs
+// with the same text, and unless the crawl was stopped early so it isn't
+// complete, gaps in the numbering and (with --refs or --strict) references to
+// paragraphs that aren't there. The numbering is only checked for an edition
+// whose last paragraph is known, or with --expected-total.
+func (c *Catechism) problems(complete bool) []string {
+	lang := c.lang
+	if lang == "" {
+		lang = "en"
+	}
+	var problems []string
+	for _, urlStr := range c.failedPages {
+		problems = append(problems, fmt.Sprintf("couldn't fetch %s", urlStr))
+	}
+	for _, urlStr := range c.emptyPages {
+		problems = append(problems, fmt.Sprintf("no elements match %q on %s", *paragraphSelector, urlStr))
+	}
+	if last := lastParagraph(lang); complete && last > 0 {
+		missing, extra := findGaps(c.Paragraphs, last)
+		if len(missing) > 0 {
+			problems = append(problems, fmt.Sprintf("missing %d paragraphs: %s", len(missing), joinNumbers(missing)))
+		}
+		if len(extra) > 0 {
+			problems = append(problems, fmt.Sprintf("found %d unexpected paragraphs: %s", len(extra), joinNumbers(extra)))
+		}
+	}
+	if !toleratesDuplicates() {
+		for _, num := range c.duplicates {
+			problems = append(problems, fmt.Sprintf("paragraph %d appears more than once, only the first was kept", num))
+		}
+	}
+	for _, num := range shortParagraphs(c.Paragraphs) {
+		problems = append(problems, fmt.Sprintf("paragraph %d is suspiciously short: %q", num, c.Paragraphs[num].Text))
+	}
+	if complete && (*verifyRefs || *strict) {
+		for _, d := range danglingReferences(c.Paragraphs) {
+			if d.To == 0 {
+				problems = append(problems, fmt.Sprintf("paragraph %d has a reference that isn't a paragraph number: %q", d.From, d.Ref))
+			} else {
+				problems = append(problems, fmt.Sprintf("paragraph %d references %d, which isn't in the crawl", d.From, d.To))
+			}
+		}
+	}
+	if *findDupes {
+		for _, d := range duplicateTexts(c.Paragraphs, *similarity) {
+			if d.Similarity == 1 {
+				problems = append(problems, fmt.Sprintf("paragraphs %d and %d have the same text", d.A, d.B))
+			} else {
+				problems = append(problems, fmt.Sprintf("paragraphs %d and %d have nearly the same text, %.0f%% alike", d.A, d.B, 100*d.Similarity))
+			}
+		}
+	}
+	return problems
+}
+
+// verify prints the problems with the crawl, and exits non-zero if there were any
+func verify(c *Catechism) {
+	problems := c.problems(true)
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		exit(1)
+	}
+	fmt.Printf("all %d paragraphs look good\n", len(c.Paragraphs))
+}
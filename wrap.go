@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/width"
+)
+
+var wrapMode = flag.String("wrap", "auto", "wrap printed paragraphs at `column`, auto for the terminal's width (and no wrapping otherwise), or 0 not to wrap")
+
+// The column to wrap at, set by setupWrap from --wrap, or 0 not to wrap
+var wrapColumn int
+
+// setupWrap decides where to wrap printed text. Like setupHyperlinks, it has to
+// run before the pager starts, to see whether stdout is a terminal.
+func setupWrap() error {
+	if *wrapMode == "auto" {
+		if isTerminal(os.Stdout) {
+			wrapColumn = terminalWidth(os.Stdout)
+		}
+		return nil
+	}
+	column, err := strconv.Atoi(*wrapMode)
+	if err != nil || column < 0 {
+		return fmt.Errorf("--wrap must be a column, auto or 0, not %q", *wrapMode)
+	}
+	wrapColumn = column
+	return nil
+}
+
+// wrapped breaks each line of text between words, so that no line is wider
+// than --wrap columns unless a single word is. Line breaks already in text are
+// kept, though a paragraph has none by then, since Paragraph.String puts it on
+// one line. A line is only ever broken at a space, so letters and the marks
+// combined with them always stay together.
+func wrapped(text string) string {
+	if wrapColumn <= 0 {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = wrapLine(line, wrapColumn)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// wrapLine wraps one line at column, keeping the spaces between the words on each line as they were
+func wrapLine(line string, column int) string {
+	var b strings.Builder
+	lineWidth := 0
+	for line != "" {
+		// The spaces before the next word, and the word
+		word := strings.TrimLeft(line, " ")
+		spaces := line[:len(line)-len(word)]
+		if i := strings.IndexByte(word, ' '); i >= 0 {
+			word = word[:i]
+		}
+		line = line[len(spaces)+len(word):]
+		w := displayWidth(word)
+		if lineWidth > 0 && lineWidth+len(spaces)+w > column {
+			b.WriteByte('\n')
+			lineWidth = 0
+		} else {
+			b.WriteString(spaces)
+			lineWidth += len(spaces)
+		}
+		b.WriteString(word)
+		lineWidth += w
+	}
+	return b.String()
+}
+
+// displayWidth returns how many columns s takes up on a terminal: combining
+// marks and other zero width characters take none, and wide East Asian
+// characters take two
+func displayWidth(s string) int {
+	n := 0
+	for _, r := range s {
+		switch {
+		case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		case width.LookupRune(r).Kind() == width.EastAsianWide, width.LookupRune(r).Kind() == width.EastAsianFullwidth:
+			n += 2
+		default:
+			n++
+		}
+	}
+	return n
+}